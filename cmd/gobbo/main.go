@@ -23,7 +23,9 @@ var app = charli.App{
 		cmds.Install,
 		cmds.Edit,
 		cmds.Run,
+		cmds.Build,
 		cmds.Export,
+		cmds.Serve,
 		cmds.Clean,
 		// cmds.Add,
 		// cmds.Remove,
@@ -4,6 +4,7 @@ go 1.23
 
 require (
 	github.com/adrg/xdg v0.5.0
+	github.com/andybalholm/brotli v1.1.1
 	github.com/fatih/color v1.17.0
 	github.com/google/go-github/v63 v63.0.0
 	github.com/gosimple/slug v1.14.0
@@ -0,0 +1,87 @@
+package cmds
+
+import (
+	"github.com/starriver/charli"
+	"github.com/starriver/gobbo/internal/opts"
+	"github.com/starriver/gobbo/pkg/exec"
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+const buildDesc = `
+Builds a C# project's solution headlessly, using Godot's {--build-solutions}.
+To build a project outside of the current directory, use {-p}/{--project}.
+
+The project's {godot} version must be a .NET ({_mono}) build, and a suitable
+.NET SDK must be installed.
+
+Unless {-n}/{--no-install} is supplied, {gobbo install} will run first.
+
+Extraneous arguments will be passed to Godot. Use {--} to prevent Gobbo
+parsing flags.
+`
+
+var Build = charli.Command{
+	Name:        "build",
+	Headline:    "Build a C# project",
+	Description: buildDesc,
+	Options: []charli.Option{
+		opts.Project,
+		{
+			Short:    'n',
+			Long:     "no-install",
+			Flag:     true,
+			Headline: "Skip dependency check and installation",
+		},
+	},
+	Args: charli.Args{
+		Varadic:  true,
+		Metavars: []string{"GODOT_ARGS"},
+	},
+
+	Run: func(r *charli.Result) {
+		opts.LogSetup(r)
+
+		store := opts.StoreSetup(r)
+
+		installMode := opts.IfAbsent
+		if r.Options["n"].IsSet {
+			installMode = opts.Never
+		}
+
+		project, godot := opts.ProjectGodotSetup(r, store, installMode, true)
+		if r.Fail {
+			return
+		}
+
+		if !project.IsCSharp() {
+			r.Errorf("'%s' isn't a C# project (no .csproj found)", project.Src)
+		} else if !godot.Mono {
+			r.Errorf("Godot %s isn't a .NET build - use a '_mono' version", godot.String())
+		}
+
+		installed, err := store.IsGodotInstalled(godot)
+		if err != nil {
+			r.Error(err)
+		} else if !installed {
+			r.Errorf("Godot %s not installed", godot.String())
+		}
+
+		if !r.Options["n"].IsSet {
+			opts.DotnetSetup(r, project, godot)
+		}
+
+		if r.Fail {
+			return
+		}
+
+		bin := store.Join("bin", godot.BinaryPath(&store.Platform))
+		args := append(
+			[]string{"--headless", "--path", project.Src, "--build-solutions", "--quit"},
+			r.Args...,
+		)
+
+		glog.Infof("Building %s with Godot %s...", project.CSProject, godot.String())
+		exec.Execv(bin, args)
+		panic("Should be unreachable")
+	},
+}
@@ -5,12 +5,17 @@ import (
 
 	"github.com/starriver/charli"
 	"github.com/starriver/gobbo/internal/opts"
+	"github.com/starriver/gobbo/pkg/export"
 	"github.com/starriver/gobbo/pkg/glog"
 )
 
 const cleanDesc = `
 Removes temporary project files in .godot/ (within Godot project's source
 directory).
+
+If {-x}/{--export-caches} is supplied, the Docker volumes Gobbo uses to cache
+Gradle, NuGet & imported files between exports are also removed. Note that
+this affects every Gobbo project, not just the current one.
 `
 
 var Clean = charli.Command{
@@ -20,6 +25,12 @@ var Clean = charli.Command{
 
 	Options: []charli.Option{
 		opts.Project,
+		{
+			Short:    'x',
+			Long:     "export-caches",
+			Flag:     true,
+			Headline: "Also remove export cache volumes",
+		},
 	},
 
 	Run: func(r *charli.Result) {
@@ -31,6 +42,23 @@ var Clean = charli.Command{
 			return
 		}
 
+		if r.Options["x"].IsSet {
+			err := export.CheckEnvironment()
+			if err != nil {
+				r.Error(err)
+				return
+			}
+
+			names, err := export.PurgeCaches()
+			if err != nil {
+				r.Error(err)
+				return
+			}
+			for _, name := range names {
+				glog.Infof("Deleted volume: '%s'", name)
+			}
+		}
+
 		path := project.GodotCachePath()
 		_, err := os.Stat(path)
 		if err != nil {
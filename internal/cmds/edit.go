@@ -11,7 +11,8 @@ const editDesc = `
 Runs the Godot editor for a project. To edit a project outside of the
 current directory, use {-p}/{--project}.
 
-Unless {-n}/{--no-install} is supplied, {gobbo install} will run first.
+Unless {-n}/{--no-install} is supplied, {gobbo install} will run first. For
+.NET builds of Godot, this also checks that a suitable .NET SDK is installed.
 
 Extraneous arguments will be passed to Godot. Use {--} to prevent Gobbo
 parsing flags.
@@ -64,6 +65,10 @@ var Edit = charli.Command{
 			r.Errorf("Godot %s not installed", godot.String())
 		}
 
+		if !r.Options["n"].IsSet {
+			opts.DotnetSetup(r, project, godot)
+		}
+
 		if r.Fail {
 			return
 		}
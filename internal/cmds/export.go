@@ -1,14 +1,18 @@
 package cmds
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 
 	"github.com/starriver/charli"
 	"github.com/starriver/gobbo/internal/opts"
 	"github.com/starriver/gobbo/pkg/export"
 	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/publish"
 	"gopkg.in/yaml.v3"
 )
 
@@ -24,14 +28,48 @@ Before exporting, various prerequisites are ensured:
 - A Docker image for the exports is built.
 - Project imports are run.
 
-Gobbo builds its own Docker image for the containers, using the
-{starriver.run/gobbo} tag. You can provide your own image by creating a
-Dockerfile in your project's root directory.
+Each cell's inputs (project source, export preset, Godot version, variant
+config & volumes, secrets, debug flag) are fingerprinted. Cells that haven't
+changed since their last successful export are skipped, unless {-f}/{--force}
+is supplied.
 
-Use '{FROM starriver.run/gobbo:latest}' in your Dockerfile to ensure you have
-all of the prerequisite dependencies available (unless you know what you're
-doing!). Note that Godot will be bind-mounted into the build containers, so
-there's no need to install it in your Dockerfile.
+Each cell's output is shown with a prefix, and written to
+{dist/logs/<cell>.log}. With {-q}/{--quiet}, only the last lines of failed
+cells' output are shown. Afterwards, each cell's result is summarized, with
+counts of Godot's {ERROR:} & {WARNING:} lines. If any cell fails, nothing is
+published.
+
+Godot's exit status isn't always reliable, so cells also fail if their output
+contains script parse errors, SCRIPT ERRORs or resources that failed to load.
+These lines are shown in the summary. To ignore some of them, add regular
+expressions matching them to {export.allow_errors}.
+
+Exports can be cancelled with Ctrl-C. The containers are torn down, and the
+dist directories of unfinished cells are removed. Finished cells are still
+recorded in the manifest, but nothing is published.
+
+If a variant has an {[export.<variant>.publish.itch]} table (or there's one in
+{[export.publish]}), its exports are pushed to itch.io with butler afterwards.
+Channels are named after each preset's platform. Use {-D}/{--dry-run-publish}
+to print the butler commands instead of running them, or {-s}/{--skip-publish}
+to skip publishing entirely.
+
+Variants with an {[export.<variant>.steam]} table get SteamPipe build scripts
+generated in {dist/.steam/<variant>}, with each depot's content laid out from
+the variant's exports. If {upload} is set, the build is uploaded with steamcmd
+as part of publishing.
+
+Gobbo builds its own Docker images for the containers, tagged
+{starriver.run/gobbo:<name>-<version>}. Each preset uses the image for its
+platform: {base}, {android} or {windows} (or their {-mono} versions for .NET
+projects). You can provide your own image by creating a Dockerfile in your
+project's root directory. It's rebuilt whenever it changes.
+
+Use '{FROM starriver.run/gobbo:latest}' (or {:android}, {:windows} etc.) in
+your Dockerfile to ensure you have all of the prerequisite dependencies
+available (unless you know what you're doing!). Note that Godot will be
+bind-mounted into the build containers, so there's no need to install it in
+your Dockerfile.
 `
 
 var Export = charli.Command{
@@ -62,7 +100,7 @@ var Export = charli.Command{
 			Short:    'r',
 			Long:     "rebuild-image",
 			Flag:     true,
-			Headline: "Always rebuild exporter Docker image",
+			Headline: "Always rebuild exporter Docker images",
 		},
 		{
 			Short:    'd',
@@ -70,6 +108,30 @@ var Export = charli.Command{
 			Flag:     true,
 			Headline: "Build debug exports",
 		},
+		{
+			Short:    'f',
+			Long:     "force",
+			Flag:     true,
+			Headline: "Export all cells, even if unchanged",
+		},
+		{
+			Short:    's',
+			Long:     "skip-publish",
+			Flag:     true,
+			Headline: "Don't publish exports",
+		},
+		{
+			Short:    'D',
+			Long:     "dry-run-publish",
+			Flag:     true,
+			Headline: "Print publish commands instead of running them",
+		},
+		{
+			Short:    'q',
+			Long:     "quiet",
+			Flag:     true,
+			Headline: "Only show the output of cells that fail",
+		},
 		{
 			Short:    'c',
 			Long:     "compose",
@@ -116,10 +178,25 @@ var Export = charli.Command{
 			return
 		}
 
+		// Printing the config shouldn't run anything, so the version is left
+		// as-is.
+		printCompose := r.Options["c"].IsSet
+		if !printCompose {
+			if err = project.ResolveVersion(); err != nil {
+				r.Error(err)
+				return
+			}
+		}
+
 		debug := r.Options["d"].IsSet
-		c := export.Configure(store, project, debug, r.Args)
+		c, err := export.Configure(store, project, debug, r.Args)
+		if err != nil {
+			r.Error(err)
+			return
+		}
 
-		if r.Options["c"].IsSet {
+		// Every cell is printed, including unchanged ones.
+		if printCompose {
 			err = yaml.NewEncoder(os.Stdout).Encode(c)
 			if err != nil {
 				r.Error(err)
@@ -127,8 +204,53 @@ var Export = charli.Command{
 			return
 		}
 
+		err = export.WriteOverrides(c, project)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+
+		prev, err := export.ReadManifest(project.Export.Dist)
+		if err != nil {
+			glog.Warnf("Couldn't read previous export manifest: %v", err)
+		}
+
+		fps, err := export.Fingerprints(c, project)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+
+		var skipped []string
+		if !r.Options["f"].IsSet {
+			skipped = export.SkipUnchanged(c, prev, fps)
+			for _, name := range skipped {
+				glog.Infof("Skipping unchanged: %s", name)
+			}
+			if len(c.Services) == 0 {
+				glog.Info("Nothing to export. Use -f/--force to export anyway.")
+				return
+			}
+		}
+
+		// Validate publishing config before exporting anything.
+		var itch []publish.ItchPush
+		if !r.Options["s"].IsSet {
+			itch, err = publish.ItchPlan(c, project)
+			if err != nil {
+				r.Error(err)
+				return
+			}
+		}
+
+		steam, err := publish.SteamPlan(c, project)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+
 		alwaysRebuild := r.Options["r"].IsSet
-		err = export.BuildImage(alwaysRebuild)
+		err = export.BuildImages(c, alwaysRebuild)
 		if err != nil {
 			r.Error(err)
 			return
@@ -150,45 +272,126 @@ var Export = charli.Command{
 		}
 
 		glog.Info("Starting exports...")
-		err = export.Run(c)
-		if err != nil {
+		quiet := r.Options["q"].IsSet
+		results, err := export.Run(c, project.Export.Dist, quiet)
+		interrupted := errors.Is(err, export.ErrCancelled)
+		if err != nil && !interrupted {
 			r.Error(err)
 			return
 		}
+		// Still record the cells that finished, but don't publish.
+		failed := summarize(results, quiet)
+
+		m, err := export.NewManifest(c, project.Export.Dist)
+		if err != nil {
+			r.Errorf("Couldn't collect exports: %v", err)
+			return
+		}
 
 		if project.Export.Zip {
 			// If we're zipping, dist subdirectories will all have just a single
 			// file - move them up for convenience.
 
-			zips := make([]string, 0, len(c.Services))
-			err := filepath.WalkDir(project.Export.Dist, func(path string, d os.DirEntry, err error) error {
+			for i, a := range m.Artifacts {
+				if path.Ext(a.Path) != ".zip" {
+					continue
+				}
+
+				z := filepath.Join(project.Export.Dist, filepath.FromSlash(a.Path))
+				dir := filepath.Dir(z)
+				dest := filepath.Join(filepath.Dir(dir), filepath.Base(z))
+				err = os.Rename(z, dest)
 				if err != nil {
-					return err
+					glog.Warnf("Couldn't move '%s' up: %v", z, err)
+					continue
 				}
+				m.Artifacts[i].Path = path.Join(path.Dir(path.Dir(a.Path)), path.Base(a.Path))
 
-				if !d.IsDir() && filepath.Ext(path) == ".zip" {
-					zips = append(zips, path)
+				err = os.Remove(dir)
+				if err != nil {
+					glog.Warnf("Couldn't remove directory '%s': %v", dir, err)
 				}
-				return nil
-			})
+			}
+		}
+
+		m.Record(fps, prev, skipped)
+		err = m.Write(project.Export.Dist)
+		if err != nil {
+			r.Errorf("Couldn't write export manifest: %v", err)
+			return
+		}
+		glog.Infof(
+			"Wrote %d artifact(s) to '%s'",
+			len(m.Artifacts),
+			filepath.Join(project.Export.Dist, export.ManifestName),
+		)
 
+		if interrupted {
+			r.Error(export.ErrCancelled)
+			return
+		}
+		if failed != 0 {
+			r.Errorf("%d cell(s) failed, so nothing was published", failed)
+			return
+		}
+
+		dryRun := r.Options["D"].IsSet
+		for _, push := range publish.Pushes(itch, m, project.Export.Dist) {
+			err = push.Run(dryRun)
+			if err != nil {
+				r.Error(err)
+			}
+		}
+
+		for _, b := range steam {
+			script, err := b.Generate(m, project.Export.Dist)
 			if err != nil {
-				glog.Warnf("Couldn't walk '%s': %v", project.Export.Dist, err)
-			} else {
-				for _, z := range zips {
-					dir := filepath.Dir(z)
-					err = os.Rename(z, filepath.Dir(dir))
-					if err != nil {
-						glog.Warnf("Couldn't move '%s' up: %v", z, err)
-						continue
-					}
-
-					err = os.Remove(dir)
-					if err != nil {
-						glog.Warnf("Couldn't remove directory '%s': %v", dir, err)
-					}
+				r.Error(err)
+				continue
+			}
+			if b.Config.Upload && !r.Options["s"].IsSet {
+				err = b.Upload(script, dryRun)
+				if err != nil {
+					r.Error(err)
 				}
 			}
 		}
 	},
 }
+
+// Log each cell's result. If quiet, the tails of failed cells' output are
+// shown too. Returns the number of cells that failed (but weren't cancelled).
+func summarize(results []*export.CellResult, quiet bool) (failed int) {
+	for i, res := range results {
+		counts := fmt.Sprintf("%d error(s), %d warning(s)", res.Errors, res.Warnings)
+
+		switch {
+		case res.Cancelled:
+			glog.Warnf("%s: cancelled", res.Cell)
+
+		case res.Failed():
+			failed++
+			if quiet {
+				for _, line := range res.Tail {
+					glog.Output(res.Cell, i, line)
+				}
+			}
+			for _, line := range res.Fatal {
+				glog.Errorf("%s: %s", res.Cell, line)
+			}
+
+			reason := fmt.Sprintf("exit code %d", res.ExitCode)
+			if res.ExitCode == 0 {
+				reason = fmt.Sprintf("%d fatal error(s)", len(res.Fatal))
+			}
+			glog.Errorf(
+				"%s: failed with %s (%s) - see '%s'",
+				res.Cell, reason, counts, res.Log,
+			)
+
+		default:
+			glog.Infof("%s: done (%s)", res.Cell, counts)
+		}
+	}
+	return
+}
@@ -13,7 +13,8 @@ const runDesc = `
 Runs Godot in the foreground. If in a project directory, or {-p}/{--project} is
 supplied, Godot will run the project.
 
-Unless {-n}/{--no-install} is supplied, {gobbo install} will run first.
+Unless {-n}/{--no-install} is supplied, {gobbo install} will run first. For
+.NET builds of Godot, this also checks that a suitable .NET SDK is installed.
 
 Extraneous arguments will be passed to Godot. Use {--} to prevent Gobbo
 parsing flags.
@@ -62,6 +63,10 @@ var Run = charli.Command{
 			r.Errorf("Godot %s not installed", godot.String())
 		}
 
+		if !r.Options["n"].IsSet {
+			opts.DotnetSetup(r, project, godot)
+		}
+
 		if r.Fail {
 			return
 		}
@@ -0,0 +1,198 @@
+package cmds
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/charli"
+	"github.com/starriver/gobbo/internal/opts"
+	"github.com/starriver/gobbo/pkg/export"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/project"
+	"github.com/starriver/gobbo/pkg/web"
+)
+
+const serveDesc = `
+Serves a web export from the project's dist directory, with the headers
+Godot 4 needs to run in the browser. Large files are precompressed with
+brotli & gzip first.
+
+If the project has multiple web exports, specify one with {EXPORT} - either
+the preset's name, or {VARIANT:PRESET} when using variants.
+
+If {-w}/{--watch} is supplied, the project source is watched, and the export
+is rebuilt with {gobbo export} whenever it changes.
+`
+
+var Serve = charli.Command{
+	Name:        "serve",
+	Headline:    "Serve a web export locally",
+	Description: serveDesc,
+	Options: []charli.Option{
+		opts.Project,
+		{
+			Short:    'a',
+			Long:     "address",
+			Metavar:  "ADDRESS",
+			Headline: "Listen address (default: localhost:8060)",
+		},
+		{
+			Short:    'w',
+			Long:     "watch",
+			Flag:     true,
+			Headline: "Rebuild the export when the project changes",
+		},
+		{
+			Short:    'P',
+			Long:     "pwa",
+			Flag:     true,
+			Headline: "Check the export is a progressive web app",
+		},
+	},
+	Args: charli.Args{
+		Varadic:  true,
+		Metavars: []string{"EXPORT"},
+	},
+
+	Run: func(r *charli.Result) {
+		opts.LogSetup(r)
+
+		project := opts.ProjectSetup(r, true)
+
+		if len(r.Args) > 1 {
+			r.Errorf("Only one export can be served at a time.")
+		}
+
+		if r.Fail {
+			return
+		}
+
+		m, err := export.ReadManifest(project.Export.Dist)
+		if err != nil {
+			r.Error(err)
+			return
+		}
+		if m == nil {
+			r.Errorf("No exports found. Run 'gobbo export' first.")
+			return
+		}
+
+		// Find the HTML file of the requested web export.
+		var matches []export.Artifact
+		for _, a := range m.Artifacts {
+			if a.Platform != "Web" || path.Ext(a.Path) != ".html" {
+				continue
+			}
+			if len(r.Args) == 1 && !matchExport(r.Args[0], &a) {
+				continue
+			}
+			matches = append(matches, a)
+		}
+
+		switch len(matches) {
+		case 0:
+			r.Errorf("No matching web exports found. Note that zipped exports can't be served.")
+			return
+		case 1: // OK
+		default:
+			r.Errorf("Multiple web exports found - specify one of:")
+			for _, a := range matches {
+				glog.Errorf("  %s", exportName(&a))
+			}
+			return
+		}
+
+		a := matches[0]
+		html := filepath.Join(project.Export.Dist, filepath.FromSlash(a.Path))
+		dir := filepath.Dir(html)
+
+		if r.Options["P"].IsSet {
+			if err = web.CheckPWA(html); err != nil {
+				r.Error(err)
+				return
+			}
+			glog.Info("PWA files present.")
+		}
+
+		if err = web.Precompress(dir); err != nil {
+			r.Error(err)
+			return
+		}
+
+		if r.Options["w"].IsSet {
+			go watch(r, project, exportName(&a), dir)
+		}
+
+		addr := "localhost:8060"
+		if opt := r.Options["a"]; opt.IsSet {
+			addr = opt.Value
+		}
+
+		glog.Infof("Serving '%s' at http://%s/", a.Path, addr)
+		err = http.ListenAndServe(addr, web.Handler(dir, filepath.Base(html)))
+		if err != nil {
+			r.Error(err)
+		}
+	},
+}
+
+// Whether an EXPORT argument refers to an artifact.
+func matchExport(arg string, a *export.Artifact) bool {
+	variant, preset, ok := strings.Cut(arg, ":")
+	if !ok {
+		preset = arg
+		variant = ""
+	}
+	if variant != a.Variant {
+		return false
+	}
+	return preset == a.Preset || preset == slug.Make(a.Preset)
+}
+
+// The artifact's name, as accepted by gobbo export & serve.
+func exportName(a *export.Artifact) string {
+	if a.Variant == "" {
+		return a.Preset
+	}
+	return a.Variant + ":" + slug.Make(a.Preset)
+}
+
+// Rebuild the export whenever the project source changes.
+func watch(r *charli.Result, project *project.Project, name, dir string) {
+	exe, err := os.Executable()
+	if err != nil {
+		glog.Errorf("Can't watch: %v", err)
+		return
+	}
+
+	exclude := []string{project.GodotCachePath(), project.Export.Dist}
+
+	glog.Infof("Watching '%s' for changes...", project.Src)
+	web.Watch(project.Src, exclude, time.Second, func() {
+		glog.Info("Changes detected, rebuilding...")
+
+		args := []string{"export", name}
+		if opt := r.Options["p"]; opt.IsSet {
+			args = append(args, "-p", opt.Value)
+		}
+		cmd := exec.Command(exe, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			glog.Errorf("Rebuild failed: %v", err)
+			return
+		}
+
+		if err := web.Precompress(dir); err != nil {
+			glog.Errorf("Couldn't precompress: %v", err)
+			return
+		}
+		glog.Info("Rebuilt. Reload the page to see changes.")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/starriver/charli"
 	"github.com/starriver/gobbo/pkg/glog"
 	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
 	"github.com/starriver/gobbo/pkg/store"
 )
 
@@ -91,3 +92,21 @@ func InstallGodot(r *charli.Result, s *store.Store, g *godot.Official, mode Inst
 		}
 	}
 }
+
+// Check for the .NET SDK if g is a .NET build. It's only an error for C#
+// projects - otherwise, the editor will run without it.
+func DotnetSetup(r *charli.Result, p *project.Project, g *godot.Official) {
+	if r.Fail || g == nil || !g.Mono {
+		return
+	}
+
+	err := g.CheckDotnet()
+	if err == nil {
+		return
+	}
+	if p != nil && p.IsCSharp() {
+		r.Error(err)
+	} else {
+		glog.Warn(err)
+	}
+}
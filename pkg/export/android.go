@@ -0,0 +1,145 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Android keystores for mount_secrets. Keystores are bind-mounted into
+// /srv/android, and credentials are passed through Compose variable
+// interpolation, so they never appear in the Compose config itself.
+
+type keystore struct {
+	Path     string
+	User     string
+	Password string
+}
+
+const androidRoot = "/srv/android"
+
+// Read the debug & release keystores for an Android preset. The debug keystore
+// defaults to the one in the editor settings; either can be set in the preset's
+// options (or export_credentials.cfg, which takes precedence).
+func androidKeystores(p *project.Project, pr *project.Preset) (debug, release keystore) {
+	for _, path := range p.Godot.EditorSettingsPaths() {
+		es, err := godot.Query(path, godot.Q{
+			"resource": {
+				"export/android/debug_keystore",
+				"export/android/debug_keystore_user",
+				"export/android/debug_keystore_pass",
+			},
+		})
+		if err != nil {
+			if !os.IsNotExist(err) {
+				glog.Warnf("Couldn't load '%s': %v", path, err)
+			}
+			continue
+		}
+
+		r := es["resource"]
+		debug.Path = godot.Unquote(r["export/android/debug_keystore"])
+		debug.User = godot.Unquote(r["export/android/debug_keystore_user"])
+		debug.Password = godot.Unquote(r["export/android/debug_keystore_pass"])
+		break
+	}
+
+	options := pr.Section + ".options"
+	q := godot.Q{
+		options: {
+			"keystore/debug",
+			"keystore/debug_user",
+			"keystore/debug_password",
+			"keystore/release",
+			"keystore/release_user",
+			"keystore/release_password",
+		},
+	}
+
+	for _, path := range []string{p.ExportPresetsPath(), p.ExportCredentialsPath()} {
+		cfg, err := godot.Query(path, q)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				glog.Warnf("Couldn't load '%s': %v", path, err)
+			}
+			continue
+		}
+
+		set := func(dest *string, key string) {
+			if v := godot.Unquote(cfg[options][key]); v != "" {
+				*dest = v
+			}
+		}
+		set(&debug.Path, "keystore/debug")
+		set(&debug.User, "keystore/debug_user")
+		set(&debug.Password, "keystore/debug_password")
+		set(&release.Path, "keystore/release")
+		set(&release.User, "keystore/release_user")
+		set(&release.Password, "keystore/release_password")
+	}
+
+	debug.Path = resolveResPath(p, debug.Path)
+	release.Path = resolveResPath(p, release.Path)
+	return
+}
+
+// Keystore paths may be relative to the project.
+func resolveResPath(p *project.Project, path string) string {
+	if path == "" {
+		return ""
+	}
+	if rel, ok := strings.CutPrefix(path, "res://"); ok {
+		return filepath.Join(p.Src, rel)
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(p.Src, path)
+	}
+	return path
+}
+
+// Mount an Android preset's keystores into a service, and register their
+// credentials as secrets on the config.
+func (c *ComposeConfig) mountAndroidKeystores(s *Service, p *project.Project, pr *project.Preset) {
+	debug, release := androidKeystores(p, pr)
+
+	for _, ks := range []struct {
+		name string
+		keystore
+	}{
+		{"debug", debug},
+		{"release", release},
+	} {
+		if ks.Path == "" {
+			glog.Debugf("%s: no %s keystore configured", pr.Name, ks.name)
+			continue
+		}
+		if _, err := os.Stat(ks.Path); err != nil {
+			glog.Warnf("%s: couldn't use %s keystore: %v", pr.Name, ks.name, err)
+			continue
+		}
+
+		target := filepath.Join(androidRoot, ks.name+".keystore")
+		s.Volumes = append(s.Volumes, project.Volume{
+			Type:     "bind",
+			Source:   ks.Path,
+			Target:   target,
+			ReadOnly: true,
+		})
+
+		prefix := "GODOT_ANDROID_KEYSTORE_" + strings.ToUpper(ks.name) + "_"
+		s.Environment.set(prefix+"PATH", target)
+
+		// eg. GOBBO_ANDROID_MY_PRESET_RELEASE_USER.
+		secret := strings.ToUpper(strings.ReplaceAll(
+			"GOBBO_ANDROID_"+slug.Make(pr.Name)+"_"+ks.name+"_",
+			"-", "_",
+		))
+		c.setSecret(s, prefix+"USER", secret+"USER", ks.User)
+		c.setSecret(s, prefix+"PASSWORD", secret+"PASSWORD", ks.Password)
+	}
+}
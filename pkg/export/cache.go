@@ -0,0 +1,116 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Named volumes persisting well-known caches between export containers.
+// They're labelled so that they can be found & purged later.
+
+const cachePrefix = "gobbo-cache-"
+const cacheLabel = "run.starriver.gobbo.cache"
+
+type cache struct {
+	name    string
+	target  string
+	enabled func(p *project.Project, pr *project.Preset) bool
+}
+
+var caches = []cache{
+	{
+		name:   "gradle",
+		target: "/root/.gradle",
+		enabled: func(p *project.Project, pr *project.Preset) bool {
+			return pr.Platform == "Android"
+		},
+	},
+	{
+		name:   "nuget",
+		target: "/root/.nuget/packages",
+		enabled: func(p *project.Project, pr *project.Preset) bool {
+			return p.IsCSharp()
+		},
+	},
+}
+
+// Cache volumes to mount for a preset's cells.
+func cacheVolumes(p *project.Project, pr *project.Preset) []project.Volume {
+	volumes := []project.Volume{}
+	for _, c := range caches {
+		if !c.enabled(p, pr) {
+			continue
+		}
+
+		volumes = append(volumes, project.Volume{
+			Type:   "volume",
+			Source: cachePrefix + c.name,
+			Target: c.target,
+		})
+	}
+	return volumes
+}
+
+// Mount a cache of Godot's imported files into each service. Unlike the other
+// caches, these are per project and per cell, so concurrent cells never write
+// to the same volume - imports also vary by platform (eg. VRAM compression)
+// and variant.
+func (c *ComposeConfig) addImportCaches(p *project.Project) {
+	id := projectID(p)
+	for name, s := range c.Services {
+		s.Volumes = append(s.Volumes, project.Volume{
+			Type:   "volume",
+			Source: cachePrefix + "imported-" + id + "-" + name,
+			Target: "/srv/src/.godot/imported",
+		})
+		c.Services[name] = s
+	}
+}
+
+// Identifies a project by name and location, so that projects with the same
+// name don't share Compose projects or caches.
+func projectID(p *project.Project) string {
+	src, err := filepath.Abs(p.Src)
+	if err != nil {
+		src = p.Src
+	}
+	hash := sha256.Sum256([]byte(src))
+	return slug.Make(p.Name) + "-" + hex.EncodeToString(hash[:4])
+}
+
+func isCache(v *project.Volume) bool {
+	return v.Type == "volume" && strings.HasPrefix(v.Source, cachePrefix)
+}
+
+// Cache volumes are declared with explicit names, so Compose won't prefix them
+// with the Compose project name.
+func cacheDeclaration(name string) map[string]any {
+	return map[string]any{
+		"name": name,
+		"labels": map[string]string{
+			cacheLabel: strings.TrimPrefix(name, cachePrefix),
+		},
+	}
+}
+
+// Remove all cache volumes. Returns the names of the removed volumes.
+func PurgeCaches() ([]string, error) {
+	cmd := command("volume", "ls", "-q", "--filter", "label="+cacheLabel)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	names := strings.Fields(string(output))
+	if len(names) == 0 {
+		return names, nil
+	}
+
+	cmd = command(append([]string{"volume", "rm"}, names...)...)
+	return names, cmd.Run()
+}
@@ -2,13 +2,14 @@ package export
 
 import (
 	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"sort"
-	"strings"
 
 	"github.com/gosimple/slug"
-	"github.com/starriver/gobbo/pkg/godot"
 	"github.com/starriver/gobbo/pkg/project"
 	"github.com/starriver/gobbo/pkg/store"
 )
@@ -19,41 +20,95 @@ type Filter [][2]string
 
 type ComposeConfig struct {
 	Services map[string]Service
-	// Volumes  map[string]map[string]any // unused for now
+	// Named volumes used by services. See addVolumes().
+	Volumes map[string]map[string]any `yaml:",omitempty"`
+	// Secrets used by services. See addSecrets().
+	Secrets map[string]Secret `yaml:",omitempty"`
+	// Secret values, keyed by variable name. These are never serialized -
+	// instead they're passed to Compose as environment variables, and
+	// referenced in the config via interpolation. See setSecretEnv() &
+	// resolveSecrets().
+	SecretEnv map[string]string `yaml:"-"`
+
+	// Images used by services, keyed by tag. See BuildImages().
+	images map[string]imageBuild
+	// Compose project name, so the project's containers can be found & torn
+	// down after the run.
+	name string
+	// Fatal Godot output to ignore. See Run().
+	allowErrors []*regexp.Regexp
+	// The secrets in Secrets, to be resolved into SecretEnv.
+	secretSources   map[string]*project.Secret
+	secretsResolved bool
 }
 
 type Service struct {
 	Image       string
-	Volumes     []Volume
+	Volumes     []project.Volume
 	Environment Environment
-	StopSignal  string `yaml:"stop_signal"`
-}
-
-type Volume struct {
-	Type     string
-	Source   string
-	Target   string
-	ReadOnly bool `yaml:"read_only,omitempty"`
-	Bind     Bind `yaml:",omitempty"`
-}
-
-type Bind struct {
-	CreateHostPath bool   `yaml:"create_host_path,omitempty"`
-	SELinux        string `yaml:",omitempty"`
+	Secrets     []string `yaml:",omitempty"`
+	StopSignal  string   `yaml:"stop_signal"`
 }
 
 type Environment struct {
 	GodotPath            string `yaml:"GODOT_PATH"`
 	GodotSettingsVersion string `yaml:"GODOT_SETTINGS_VERSION"`
+	GodotVersion         string `yaml:"GODOT_VERSION"`
 	ProjectName          string `yaml:"PROJECT_NAME"`
 	ProjectVersion       string `yaml:"PROJECT_VERSION"`
 	ExportPreset         string `yaml:"EXPORT_PRESET"`
+	ExportPlatform       string `yaml:"EXPORT_PLATFORM"`
 	ExportVariant        string `yaml:"EXPORT_VARIANT"`
 	ExportDebug          string `yaml:"EXPORT_DEBUG"`
 	Extension            string `yaml:"EXTENSION"`
-	ScriptPre            string `yaml:"SCRIPT_PRE"`
-	ScriptPost           string `yaml:"SCRIPT_POST"`
-	Zip                  string `yaml:"ZIP"`
+	// From export.filename, minus the extension.
+	Filename   string `yaml:"FILENAME"`
+	ScriptPre  string `yaml:"SCRIPT_PRE"`
+	ScriptPost string `yaml:"SCRIPT_POST"`
+	Zip        string `yaml:"ZIP"`
+	// Any other variables, eg. for platform-specific configuration.
+	Extra map[string]string `yaml:",inline"`
+}
+
+func (e *Environment) set(k, v string) {
+	if e.Extra == nil {
+		e.Extra = map[string]string{}
+	}
+	e.Extra[k] = v
+}
+
+func (c *ComposeConfig) setSecretEnv(variable, value string) {
+	if c.SecretEnv == nil {
+		c.SecretEnv = map[string]string{}
+	}
+	c.SecretEnv[variable] = value
+}
+
+// Set a service environment variable to a secret value, without the value
+// appearing in the Compose config.
+func (c *ComposeConfig) setSecret(s *Service, k, variable, value string) {
+	if value == "" {
+		return
+	}
+	c.setSecretEnv(variable, value)
+	s.Environment.set(k, "${"+variable+"}")
+}
+
+// Index of the dist bind mount in Service.Volumes.
+const distVolume = 3
+
+// Host path of the service's dist directory.
+func (s *Service) Dist() string {
+	return s.Volumes[distVolume].Source
+}
+
+// Host path of a cell's dist directory. variant is empty if there are no
+// variants.
+func CellDir(dist, variant, preset string) string {
+	if variant == "" {
+		return filepath.Join(dist, preset)
+	}
+	return filepath.Join(dist, variant, slug.Make(preset))
 }
 
 // Stolen from: https://github.com/juliangruber/go-intersect/
@@ -72,77 +127,58 @@ func intersect(a []string, b []string) []string {
 	return set
 }
 
-// TODO proper config here - atm we can only append short-format
-// bind-mount volumes. In order to do this, we'll need to implement
-// array handling in the project TOML loader and move the Volume and
-// Bind structs there.
-func parseVolume(s string) Volume {
-	v := Volume{
-		Type: "bind",
-		Bind: Bind{
-			CreateHostPath: true,
-		},
-	}
-
-	split := strings.Split(s, ":")
-
-	switch len(split) {
-	case 2, 3: // OK
-	default:
-		panic([]any{"Unknown volume format", s})
-	}
-
-	if len(split) == 3 {
-		flags := strings.Split(split[2], ",")
-		for _, flag := range flags {
-			switch flag {
-			case "z", "Z":
-				v.Bind.SELinux = flag
-			case "ro":
-				v.ReadOnly = true
-			default:
-				panic([]any{"Unimplemented volume flag", flag})
-			}
-		}
-	}
-
-	v.Source = split[0]
-	switch v.Source {
-	case ".", "/": // OK
-	default:
-		panic([]any{"Non bind mount volumes not yet implemented"})
-	}
-
-	v.Target = split[1]
-	if v.Target[0] != '/' {
-		panic([]any{"Bind mount target must be an absolute path", v.Target})
-	}
-
-	return v
-}
-
 var platformExts = make(map[string]string, 6)
 
 func init() {
 	platformExts["Android"] = "apk"
 	platformExts["iOS"] = "ipa"
 	platformExts["Linux"] = ""
-	platformExts["macOS"] = "app"
+	// Godot can't write .app bundles or DMGs on Linux - see macos.go.
+	platformExts["macOS"] = "zip"
 	platformExts["Web"] = "html"
 	platformExts["Windows Desktop"] = "exe"
 }
 
-func Configure(store *store.Store, p *project.Project, debug bool, filter []string) (c *ComposeConfig) {
-	c = &ComposeConfig{}
+func Configure(store *store.Store, p *project.Project, debug bool, filter []string) (c *ComposeConfig, err error) {
+	c = &ComposeConfig{
+		name:        "gobbo-" + projectID(p),
+		allowErrors: p.Export.AllowErrors,
+	}
 
 	presetNames := make([]string, len(p.Export.Presets))
 	for i, p := range p.Export.Presets {
 		presetNames[i] = p.Name
 	}
 
+	if p.IsCSharp() && !p.Godot.Mono {
+		err = fmt.Errorf(
+			"'%s' is a C# project, but Godot %s isn't a .NET build - use a '_mono' version in the godot key",
+			p.CSProject, p.Godot.String(),
+		)
+		return
+	}
+
 	godotSource := store.Join("bin", p.Godot.BinaryPath(&store.Platform))
 	godotTarget := "/opt/godot-" + p.Godot.String()
-	exportTemplateSource := godot.ExportTemplatesRoot()
+	godotPath := godotTarget
+	if p.Godot.Mono {
+		// .NET builds need their GodotSharp directory, so mount the whole
+		// installation.
+		godotPath = godotTarget + "/" + filepath.Base(godotSource)
+		godotSource = filepath.Dir(godotSource)
+	}
+
+	exportTemplateSource := p.Godot.ExportTemplatesPath()
+	if _, err = os.Stat(exportTemplateSource); err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf(
+				"export templates for Godot %s aren't installed (expected '%s')",
+				p.Godot.String(), exportTemplateSource,
+			)
+		}
+		return
+	}
+	exportTemplateTarget := "/root/.local/share/godot/export_templates/" + filepath.Base(exportTemplateSource)
 
 	// Start by creating a prospective service per preset.
 
@@ -181,8 +217,7 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 		slugPreset := slug.Make(pr.Name)
 
 		s := Service{}
-		s.Image = Tag
-		s.Volumes = []Volume{
+		s.Volumes = []project.Volume{
 			{
 				Type:     "bind",
 				Source:   godotSource,
@@ -192,7 +227,7 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 			{
 				Type:     "bind",
 				Source:   exportTemplateSource,
-				Target:   "/root/.local/share/godot/export_templates",
+				Target:   exportTemplateTarget,
 				ReadOnly: true,
 			},
 			{
@@ -202,21 +237,20 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 				ReadOnly: true,
 			},
 			// nb. this may be modified in the variant config later -
-			// Volumes[3] is hard-coded.
+			// see distVolume.
 			{
 				Type:   "bind",
-				Source: filepath.Join(p.Export.Dist, pr.Name),
+				Source: CellDir(p.Export.Dist, "", pr.Name),
 				Target: "/srv/dist",
-				Bind: Bind{
+				Bind: project.Bind{
 					CreateHostPath: true,
 					SELinux:        "z",
 				},
 			},
 		}
 
-		for _, str := range p.Export.Volumes {
-			s.Volumes = append(s.Volumes, parseVolume(str))
-		}
+		s.Volumes = append(s.Volumes, cacheVolumes(p, &pr)...)
+		s.Volumes = append(s.Volumes, p.Export.Volumes...)
 
 		debugStr := "0"
 		if debug {
@@ -230,11 +264,13 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 		}
 
 		s.Environment = Environment{
-			GodotPath:            godotTarget,
+			GodotPath:            godotPath,
 			GodotSettingsVersion: settingsVersion,
+			GodotVersion:         p.Godot.String(),
 			ProjectName:          p.Name,
 			ProjectVersion:       p.Version,
 			ExportPreset:         pr.Name,
+			ExportPlatform:       pr.Platform,
 			ExportVariant:        "",
 			ExportDebug:          debugStr,
 			Extension:            ext,
@@ -243,6 +279,20 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 			Zip:                  zip,
 		}
 
+		if p.Export.Version != nil {
+			// Write the derived version into the source copy's project.godot.
+			s.Environment.set("INJECT_VERSION", "1")
+		}
+
+		if p.IsCSharp() {
+			// Build the C# project before exporting.
+			s.Environment.set("DOTNET_PROJECT", p.CSProject)
+		}
+
+		if p.Export.MountSecrets && pr.Platform == "Android" {
+			c.mountAndroidKeystores(&s, p, &pr)
+		}
+
 		// There's no point in waiting for Godot to clean up if we Ctrl-C the
 		// exports, so:
 		s.StopSignal = "SIGKILL"
@@ -253,6 +303,14 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 	// At this point, we have everything we need for a no-variants config.
 	if len(p.Export.Variants) == 0 {
 		c.Services = presetServices
+		for name, s := range c.Services {
+			if err = c.finishService(&s, p, nil); err != nil {
+				return
+			}
+			c.Services[name] = s
+		}
+		c.addImportCaches(p)
+		c.addVolumes()
 		return
 	}
 
@@ -283,14 +341,17 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 				continue
 			}
 
+			// The volumes are shared between cells of the same preset, so copy
+			// them before modifying.
+			s.Volumes = slices.Clone(s.Volumes)
+			s.Environment.Extra = maps.Clone(s.Environment.Extra)
+
 			// Make dist one level deeper.
-			s.Volumes[3].Source = filepath.Join(
-				p.Export.Dist, variantName, preset,
+			s.Volumes[distVolume].Source = CellDir(
+				p.Export.Dist, variantName, s.Environment.ExportPreset,
 			)
 
-			for _, str := range p.Export.Volumes {
-				s.Volumes = append(s.Volumes, parseVolume(str))
-			}
+			s.Volumes = append(s.Volumes, variant.Volumes...)
 
 			s.Environment.ExportVariant = variantName
 			if variant.Scripts.Pre != "" {
@@ -300,10 +361,84 @@ func Configure(store *store.Store, p *project.Project, debug bool, filter []stri
 				s.Environment.ScriptPost = variant.Scripts.Post
 			}
 
+			if err = c.finishService(&s, p, variant); err != nil {
+				return
+			}
+
 			sName := fmt.Sprintf("%s_%s", slug.Make(variantName), preset)
 			c.Services[sName] = s
 		}
 	}
 
+	c.addImportCaches(p)
+	c.addVolumes()
 	return
 }
+
+// Per-cell configuration, once the service's preset & variant are known.
+// variant is nil if there are no variants.
+func (c *ComposeConfig) finishService(s *Service, p *project.Project, variant *project.Variant) error {
+	env := &s.Environment
+	filename, err := p.Filename(project.FilenameData{
+		Project:  p.Name,
+		Version:  p.Version,
+		Preset:   env.ExportPreset,
+		Platform: env.ExportPlatform,
+		Variant:  env.ExportVariant,
+		Debug:    env.ExportDebug == "1",
+	})
+	if err != nil {
+		return err
+	}
+	env.Filename = filename
+
+	s.Image, err = c.imageFor(p, variant, env.ExportPlatform)
+	if err != nil {
+		return err
+	}
+
+	addOverrides(s, p, variant)
+
+	c.addSecrets(s, p)
+
+	macos := p.Export.MacOS
+	if variant != nil && variant.MacOS != nil {
+		macos = variant.MacOS
+	}
+	addMacOS(s, p, macos)
+
+	linux := p.Export.Linux
+	if variant != nil && variant.Linux != nil {
+		linux = variant.Linux
+	}
+	if err := addLinux(s, p, linux); err != nil {
+		return err
+	}
+
+	sign := p.Export.Sign
+	if variant != nil && variant.Sign != nil {
+		sign = variant.Sign
+	}
+	c.addSigning(s, p, sign)
+	return nil
+}
+
+// Compose requires named volumes to be declared at the top level.
+func (c *ComposeConfig) addVolumes() {
+	for _, s := range c.Services {
+		for _, v := range s.Volumes {
+			if v.Type != "volume" {
+				continue
+			}
+			if c.Volumes == nil {
+				c.Volumes = map[string]map[string]any{}
+			}
+
+			if isCache(&v) {
+				c.Volumes[v.Source] = cacheDeclaration(v.Source)
+			} else {
+				c.Volumes[v.Source] = map[string]any{}
+			}
+		}
+	}
+}
@@ -12,9 +12,33 @@ log 'Preparing environment'
 # Create a writeable copy of the source.
 # NOTE: -a is used because the import files' timestamps need to be later than
 # the source files, or Godot will freeze during the export.
-cp -a /srv/src-ro /srv/src
+# /srv/src may already exist, as cache volumes are mounted beneath it.
+mkdir -p /srv/src
+cp -a /srv/src-ro/. /srv/src
 cd /srv/src
 
+# Apply the variant's project.godot & export_presets.cfg overrides.
+if [ -d /srv/overrides ]; then
+	log 'Applying variant overrides'
+	cp /srv/overrides/* /srv/src
+fi
+
+# Replace config/version with the version derived by Gobbo, so the exported
+# game's ProjectSettings agree with it.
+if [ "${INJECT_VERSION:-0}" == 1 ]; then
+	log "Setting version to $PROJECT_VERSION"
+	awk -v line="config/version=\"$PROJECT_VERSION\"" '
+		/^\[/ {
+			if (app && !done) { print line; done = 1 }
+			app = ($0 == "[application]")
+		}
+		app && /^config\/version=/ { print line; done = 1; next }
+		{ print }
+		END { if (app && !done) print line }
+	' project.godot > project.godot.tmp
+	mv project.godot.tmp project.godot
+fi
+
 # Place editor settings.
 (
 	mkdir -p ~/.config/godot
@@ -30,23 +54,175 @@ fi
 
 # Prepare flags.
 EXPORT_FLAG='--export-release'
+DOTNET_CONFIGURATION='ExportRelease'
 if [ "$EXPORT_DEBUG" == 1 ]; then
 	EXPORT_FLAG='--export-debug'
+	DOTNET_CONFIGURATION='ExportDebug'
 fi
 
-FILENAME="$PROJECT_NAME"
-if [ -n "$PROJECT_VERSION" ]; then
-	FILENAME="$FILENAME-$PROJECT_VERSION"
+# Build C# projects up front, so compile errors fail clearly. Godot builds
+# again during the export, but with the restored packages & build cached.
+if [ -n "${DOTNET_PROJECT:-}" ]; then
+	log "Building C# project ($DOTNET_PROJECT)"
+	dotnet restore "$DOTNET_PROJECT"
+	dotnet build --no-restore -c "$DOTNET_CONFIGURATION" "$DOTNET_PROJECT"
 fi
-if [ -n "$EXPORT_VARIANT" ]; then
-	FILENAME="$FILENAME-$EXPORT_VARIANT"
-fi
-FILENAME="$FILENAME-$EXPORT_PRESET"
+
+# FILENAME is templated from export.filename by Gobbo.
 
 log 'Exporting'
-"$GODOT_PATH" --headless "$EXPORT_FLAG" "$EXPORT_PRESET" "/srv/dist/$FILENAME.$EXTENSION"
+# Linux exports have no extension.
+"$GODOT_PATH" --headless "$EXPORT_FLAG" "$EXPORT_PRESET" "/srv/dist/$FILENAME${EXTENSION:+.$EXTENSION}"
 # exit 0
 
+if [ "${SIGN_WINDOWS:-0}" == 1 ] && [ "$EXTENSION" == exe ]; then
+	log 'Signing'
+	EXE="/srv/dist/$FILENAME.exe"
+
+	# If signing fails, remove the export so the cell isn't considered
+	# successful.
+	fail_signing() {
+		log "Signing failed: $1"
+		rm -f "/srv/dist/$FILENAME".*
+		exit 1
+	}
+
+	if [ "$SIGN_EMBEDDED_PCK" == 1 ] && [ -e "/srv/dist/$FILENAME.pck" ]; then
+		fail_signing 'PCK is not embedded in the executable (see binary_format/embed_pck)'
+	fi
+
+	SIGN_ARGS=(-pkcs12 "$SIGN_CERTIFICATE")
+	if [ -n "${SIGN_PASSWORD:-}" ]; then
+		SIGN_ARGS+=(-readpass "$SIGN_PASSWORD")
+	fi
+	if [ -n "$SIGN_DESCRIPTION" ]; then
+		SIGN_ARGS+=(-n "$SIGN_DESCRIPTION")
+	fi
+	if [ -n "$SIGN_URL" ]; then
+		SIGN_ARGS+=(-i "$SIGN_URL")
+	fi
+	if [ -n "$SIGN_TIMESTAMP" ]; then
+		SIGN_ARGS+=(-ts "$SIGN_TIMESTAMP")
+	fi
+
+	osslsigncode sign "${SIGN_ARGS[@]}" -in "$EXE" -out "$EXE.signed" \
+		|| fail_signing 'osslsigncode sign'
+	mv "$EXE.signed" "$EXE"
+
+	if [ "$SIGN_VERIFY" == 1 ]; then
+		log 'Verifying signature'
+		osslsigncode verify -in "$EXE" || fail_signing 'osslsigncode verify'
+	fi
+fi
+
+if [ "${MACOS_PACKAGE:-0}" == 1 ] && [ "$EXPORT_PLATFORM" == macOS ]; then
+	log 'Packaging macOS bundle'
+	ZIPFILE="/srv/dist/$FILENAME.zip"
+
+	# As with signing, remove the export if anything fails.
+	fail_packaging() {
+		log "Packaging failed: $1"
+		rm -f "/srv/dist/$FILENAME".*
+		exit 1
+	}
+
+	STAGE="$(mktemp -d)"
+	unzip -q "$ZIPFILE" -d "$STAGE" || fail_packaging 'unzip'
+	APP="$(find "$STAGE" -maxdepth 1 -name '*.app' -print -quit)"
+	[ -n "$APP" ] || fail_packaging 'no .app bundle in the export'
+
+	if [ "$MACOS_CODESIGN" == 1 ]; then
+		log 'Codesigning (ad-hoc)'
+		rcodesign sign "$APP" || fail_packaging 'rcodesign sign'
+	fi
+
+	# Rezip, preserving the bundle's symlinks.
+	rm "$ZIPFILE"
+	(cd "$STAGE" && zip -qry "$ZIPFILE" "$(basename "$APP")") \
+		|| fail_packaging 'zip'
+
+	if [ "$MACOS_DMG" == 1 ]; then
+		log 'Creating DMG'
+		ln -s /Applications "$STAGE/Applications"
+		genisoimage -quiet -V "$MACOS_VOLUME_NAME" -D -R -apple -no-pad \
+			-o "$STAGE.iso" "$STAGE" || fail_packaging 'genisoimage'
+		DMG="/srv/dist/$FILENAME.dmg"
+		# dmg exits successfully on bad usage, so check the output too.
+		dmg dmg "$STAGE.iso" "$DMG" || fail_packaging 'dmg'
+		[ -f "$DMG" ] || fail_packaging 'dmg wrote no output'
+		rm "$STAGE.iso"
+	fi
+
+	rm -rf "$STAGE"
+fi
+
+if [ "${LINUX_PACKAGE:-0}" == 1 ] && [ "$EXPORT_PLATFORM" == Linux ]; then
+	log 'Packaging for Linux'
+	BIN="/srv/dist/$FILENAME"
+	PCK="/srv/dist/$FILENAME.pck"
+	FLATPAK_DIR="/srv/dist/$FILENAME-flatpak"
+
+	fail_packaging() {
+		log "Packaging failed: $1"
+		rm -rf "$BIN" "/srv/dist/$FILENAME".* "$FLATPAK_DIR"
+		exit 1
+	}
+
+	STAGE="$(mktemp -d)"
+	ICON_EXT="${LINUX_ICON##*.}"
+
+	# Copy the export into a directory, named after the package.
+	stage_export() {
+		mkdir -p "$1"
+		cp "$BIN" "$1/$LINUX_NAME"
+		if [ -e "$PCK" ]; then
+			cp "$PCK" "$1/$LINUX_NAME.pck"
+		fi
+	}
+
+	# Write the desktop entry & icon to a directory.
+	stage_desktop() {
+		printf '%s\n' "$LINUX_DESKTOP_ENTRY" > "$1/$LINUX_DESKTOP_ID.desktop"
+		if [ -n "$LINUX_ICON" ]; then
+			mkdir -p "$2"
+			cp "$LINUX_ICON" "$2/$LINUX_DESKTOP_ID.$ICON_EXT"
+		fi
+	}
+
+	if [ "$LINUX_APPIMAGE" == 1 ]; then
+		log 'Building AppImage'
+		APPDIR="$STAGE/AppDir"
+		stage_export "$APPDIR/usr/bin"
+		stage_desktop "$APPDIR" "$APPDIR"
+		printf '#!/bin/sh\nexec "$(dirname "$(readlink -f "$0")")/usr/bin/%s" "$@"\n' \
+			"$LINUX_NAME" > "$APPDIR/AppRun"
+		chmod +x "$APPDIR/AppRun"
+		ARCH="$LINUX_APPIMAGE_ARCH" appimagetool -n "$APPDIR" "/srv/dist/$FILENAME.AppImage" \
+			|| fail_packaging 'appimagetool'
+	fi
+
+	if [ "$LINUX_DEB" == 1 ]; then
+		log 'Building .deb'
+		DEB="$STAGE/deb"
+		stage_export "$DEB/usr/lib/$LINUX_NAME"
+		mkdir -p "$DEB/DEBIAN" "$DEB/usr/bin" "$DEB/usr/share/applications"
+		ln -s "/usr/lib/$LINUX_NAME/$LINUX_NAME" "$DEB/usr/bin/$LINUX_NAME"
+		stage_desktop "$DEB/usr/share/applications" "$DEB/usr/share/icons/hicolor/$LINUX_ICON_SIZE/apps"
+		printf '%s\n' "$LINUX_DEB_CONTROL" > "$DEB/DEBIAN/control"
+		dpkg-deb --root-owner-group --build "$DEB" "/srv/dist/$FILENAME.deb" \
+			|| fail_packaging 'dpkg-deb'
+	fi
+
+	if [ "$LINUX_FLATPAK" == 1 ]; then
+		log 'Writing Flatpak manifest'
+		stage_export "$FLATPAK_DIR"
+		stage_desktop "$FLATPAK_DIR" "$FLATPAK_DIR"
+		printf '%s\n' "$LINUX_FLATPAK_MANIFEST" > "$FLATPAK_DIR/$LINUX_DESKTOP_ID.json"
+	fi
+
+	rm -rf "$STAGE"
+fi
+
 if [ "$ZIP" == 1 ] && [ "$EXTENSION" != zip ]; then
 (
 	log 'Zipping'
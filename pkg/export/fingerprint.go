@@ -0,0 +1,229 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Fingerprints of each cell's inputs, used to skip cells that haven't changed
+// since their last successful build.
+
+// Hash every service's inputs. The returned map is keyed by service name.
+func Fingerprints(c *ComposeConfig, p *project.Project) (map[string]string, error) {
+	// Secret values are hashed, as they aren't in the config.
+	if err := c.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	// The source tree is shared by every cell, so hash it once. Godot's cache
+	// and dist (which may be inside src) are left out.
+	src, err := hashTree(p.Src, p.GodotCachePath(), p.Export.Dist)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't hash '%s': %v", p.Src, err)
+	}
+
+	sections := map[string]string{}
+	if len(p.Export.Presets) != 0 {
+		sections, err = godot.Sections(p.ExportPresetsPath())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	presets := make(map[string]string, len(p.Export.Presets))
+	for _, pr := range p.Export.Presets {
+		presets[pr.Name] = pr.Section
+	}
+
+	fps := make(map[string]string, len(c.Services))
+	for name, s := range c.Services {
+		h := sha256.New()
+		h.Write(src)
+
+		section := presets[s.Environment.ExportPreset]
+		io.WriteString(h, sections[section])
+		io.WriteString(h, sections[section+".options"])
+		// Image tags change with their build config - apart from the
+		// embedded images', which are covered by the context hash.
+		io.WriteString(h, s.Image)
+		io.WriteString(h, contextHash())
+
+		// The environment covers the Godot version, debug flag & scripts.
+		b, err := json.Marshal(s.Environment)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(b)
+
+		secrets, err := c.secretDigests(&s)
+		if err != nil {
+			return nil, err
+		}
+		b, err = json.Marshal(secrets)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(b)
+
+		// Overrides are generated from the source (already covered) and the
+		// variant's config, so hash the latter rather than the written files.
+		if variant := p.Export.Variants[s.Environment.ExportVariant]; variant != nil {
+			b, err = json.Marshal([]any{
+				variant.Settings,
+				variant.Features,
+				presetOptions(variant, s.Environment.ExportPreset),
+			})
+			if err != nil {
+				return nil, err
+			}
+			h.Write(b)
+		}
+
+		// Volumes are hashed by configuration, and user volumes by content
+		// too, as they're likely to hold the variant's scripts.
+		for i, v := range s.Volumes {
+			b, err = json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			h.Write(b)
+
+			if i <= distVolume || v.Type != "bind" || v.Target == overridesTarget {
+				continue
+			}
+			tree, err := hashTree(v.Source)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, fmt.Errorf("couldn't hash '%s': %v", v.Source, err)
+			}
+			h.Write(tree)
+		}
+
+		fps[name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return fps, nil
+}
+
+// Digests of the secret values a service uses, keyed by secret (or variable)
+// name. Rotating a secret - eg. a signing certificate or keystore password -
+// then changes the fingerprint.
+func (c *ComposeConfig) secretDigests(s *Service) (map[string]string, error) {
+	digests := map[string]string{}
+	digest := func(b []byte) string {
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+
+	for _, name := range s.Secrets {
+		decl := c.Secrets[name]
+		if decl.File == "" {
+			digests[name] = digest([]byte(c.SecretEnv[decl.Environment]))
+			continue
+		}
+		b, err := os.ReadFile(decl.File)
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s': %v", name, err)
+		}
+		digests[name] = digest(b)
+	}
+
+	// Android keystore credentials are interpolated into the environment
+	// instead.
+	for _, v := range s.Environment.Extra {
+		variable, ok := strings.CutPrefix(v, "${")
+		if !ok {
+			continue
+		}
+		variable = strings.TrimSuffix(variable, "}")
+		digests[variable] = digest([]byte(c.SecretEnv[variable]))
+	}
+
+	return digests, nil
+}
+
+// Remove services whose fingerprints match the last successful build recorded
+// in prev. Returns the names of the removed services.
+func SkipUnchanged(c *ComposeConfig, prev *Manifest, fps map[string]string) (skipped []string) {
+	if prev == nil {
+		return
+	}
+
+	for name := range c.Services {
+		if fp, ok := prev.Cells[name]; ok && fp == fps[name] {
+			glog.Debugf("Unchanged: %s (%s)", name, fp)
+			delete(c.Services, name)
+			skipped = append(skipped, name)
+		}
+	}
+
+	return
+}
+
+// Hash a file or directory's paths & contents. Paths in exclude (and anything
+// beneath them) are skipped.
+func hashTree(root string, exclude ...string) ([]byte, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		for _, e := range exclude {
+			if path == e {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		io.WriteString(h, filepath.ToSlash(rel))
+		h.Write([]byte{0})
+
+		switch {
+		case d.IsDir():
+			return nil
+		case d.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, target)
+			return nil
+		}
+
+		return hashFile(h, path)
+	})
+
+	return h.Sum(nil), err
+}
+
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	return err
+}
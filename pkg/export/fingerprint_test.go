@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashTreeExclude(t *testing.T) {
+	root := t.TempDir()
+	cache := filepath.Join(root, ".godot")
+	os.Mkdir(cache, 0o755)
+	os.WriteFile(filepath.Join(root, "main.gd"), []byte("extends Node"), 0o644)
+
+	before, err := hashTree(root, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(cache, "imported"), []byte("junk"), 0o644)
+	after, err := hashTree(root, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Excluded directory changed the hash")
+	}
+
+	os.WriteFile(filepath.Join(root, "main.gd"), []byte("extends Node2D"), 0o644)
+	after, err = hashTree(root, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(before, after) {
+		t.Error("Changed file didn't change the hash")
+	}
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	c := &ComposeConfig{
+		Services: map[string]Service{
+			"linux": {},
+			"web":   {},
+		},
+	}
+	prev := &Manifest{
+		Cells: map[string]string{
+			"linux": "aaa",
+			"web":   "bbb",
+		},
+	}
+	fps := map[string]string{
+		"linux": "aaa",
+		"web":   "ccc",
+	}
+
+	skipped := SkipUnchanged(c, prev, fps)
+	if len(skipped) != 1 || skipped[0] != "linux" {
+		t.Errorf("Got skipped %v, expected [linux]", skipped)
+	}
+	if _, ok := c.Services["web"]; !ok || len(c.Services) != 1 {
+		t.Errorf("Got services %v, expected [web]", c.Services)
+	}
+
+	m := &Manifest{Cells: map[string]string{}}
+	m.Record(fps, prev, skipped)
+	if m.Cells["linux"] != "aaa" {
+		t.Errorf("Skipped cell's fingerprint wasn't carried over: %v", m.Cells)
+	}
+	if _, ok := m.Cells["web"]; ok {
+		t.Error("Cell without artifacts was recorded")
+	}
+}
+
+func TestSecretDigests(t *testing.T) {
+	cert := filepath.Join(t.TempDir(), "cert.p12")
+	os.WriteFile(cert, []byte("old"), 0o600)
+
+	c := &ComposeConfig{
+		Secrets: map[string]Secret{
+			"cert":     {File: cert},
+			"password": {Environment: "GOBBO_SECRET_PASSWORD"},
+		},
+		SecretEnv: map[string]string{"GOBBO_SECRET_PASSWORD": "hunter2"},
+	}
+	s := &Service{Secrets: []string{"cert", "password"}}
+	s.Environment.set("KEYSTORE_PASSWORD", "${GOBBO_ANDROID_PASSWORD}")
+
+	before, err := c.secretDigests(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 3 {
+		t.Errorf("Got %v", before)
+	}
+
+	os.WriteFile(cert, []byte("new"), 0o600)
+	c.SecretEnv["GOBBO_SECRET_PASSWORD"] = "hunter3"
+	c.SecretEnv["GOBBO_ANDROID_PASSWORD"] = "swordfish"
+	after, err := c.secretDigests(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range before {
+		if after[k] == v {
+			t.Errorf("%s: digest didn't change", k)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Images for export services. Each distinct image is built once by
+// BuildImages().
+
+// Version of the embedded images' tags.
+const ImageVersion = "v2"
+
+// Embedded images are labelled with a hash of their build context (config/),
+// so they're rebuilt when it changes.
+const contextLabel = "run.starriver.gobbo.context"
+
+// Repository for the embedded images. Each is tagged with its name & version,
+// eg. "android-v2", and with its name alone (for project Dockerfiles' FROM
+// lines). The base image is also tagged "latest".
+const imageRepo = "starriver.run/gobbo"
+
+const LatestTag = imageRepo + ":latest"
+
+// Images built from project Dockerfiles are tagged with this prefix, the
+// project's ID, and a hash of the Dockerfile.
+const projectImagePrefix = "starriver.run/gobbo-project/"
+
+// Embedded image targets (see config/Dockerfile), by preset platform. Other
+// platforms use the base image.
+var platformImages = map[string]string{
+	"Android":         "android",
+	"Windows Desktop": "windows",
+}
+
+type imageBuild struct {
+	// Host path of the Dockerfile. Empty for the embedded config.
+	dockerfile string
+	context    string
+	// For embedded images: the name of the image, eg. "android-mono".
+	name string
+}
+
+func (c *ComposeConfig) addImage(tag string, build imageBuild) {
+	if c.images == nil {
+		c.images = map[string]imageBuild{}
+	}
+	c.images[tag] = build
+}
+
+// The image for a cell. In order of precedence, it's taken from the variant,
+// the preset's platform in [export.images], or [export] - otherwise, if the
+// project has a Dockerfile in its root directory, that's used. Failing all
+// that, it's the embedded image for the platform.
+func (c *ComposeConfig) imageFor(p *project.Project, variant *project.Variant, platform string) (string, error) {
+	img := p.Export.Image
+	if i, ok := p.Export.Images[slug.Make(platform)]; ok {
+		img = i
+	}
+	if i, ok := p.Export.Images[platform]; ok {
+		img = i
+	}
+	if variant != nil && variant.Image != nil {
+		img = variant.Image
+	}
+
+	if img == nil {
+		path := filepath.Join(p.Root, "Dockerfile")
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return c.embeddedImage(platformImage(p, platform)), nil
+			}
+			return "", err
+		}
+		img = &project.Image{Dockerfile: path}
+	}
+
+	if img.Image != "" {
+		// Prebuilt - Compose will pull it if necessary.
+		return img.Image, nil
+	}
+	return c.dockerfileImage(p, img.Dockerfile)
+}
+
+// Add a build for a project Dockerfile, tagged with the file's hash. Any
+// embedded images it's based on are built too.
+func (c *ComposeConfig) dockerfileImage(p *project.Project, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(b)
+	tag := projectImagePrefix + projectID(p) + ":" + hex.EncodeToString(hash[:6])
+	for _, m := range fromRe.FindAllStringSubmatch(string(b), -1) {
+		name := strings.TrimSuffix(m[1], "-"+ImageVersion)
+		if name == "latest" {
+			name = "base"
+		}
+		if !isEmbeddedImage(name) {
+			glog.Warnf("%s: unknown Gobbo image '%s:%s'", path, imageRepo, m[1])
+			continue
+		}
+		c.embeddedImage(name)
+	}
+
+	c.addImage(tag, imageBuild{dockerfile: path, context: p.Root})
+	return tag, nil
+}
+
+var fromRe = regexp.MustCompile(`(?im)^\s*FROM\s+(?:--\S+\s+)*` + regexp.QuoteMeta(imageRepo) + `:(\S+)`)
+
+// Name of the embedded image for a platform, eg. "windows" or "android-mono".
+func platformImage(p *project.Project, platform string) string {
+	name, ok := platformImages[platform]
+	if !ok {
+		name = "base"
+	}
+	if p.Godot != nil && p.Godot.Mono {
+		if name == "base" {
+			return "mono"
+		}
+		name += "-mono"
+	}
+	return name
+}
+
+func isEmbeddedImage(name string) bool {
+	platform := strings.TrimSuffix(name, "-mono")
+	return name == "base" || name == "mono" ||
+		slices.Contains(slices.Collect(maps.Values(platformImages)), platform)
+}
+
+// Add a build for an embedded image, and return its tag.
+func (c *ComposeConfig) embeddedImage(name string) string {
+	tag := embeddedTag(name)
+	c.addImage(tag, imageBuild{name: name})
+	return tag
+}
+
+func embeddedTag(name string) string {
+	return imageRepo + ":" + name + "-" + ImageVersion
+}
+
+var contextHashCache string
+
+// Hash of the embedded build context.
+func contextHash() string {
+	if contextHashCache == "" {
+		h := sha256.New()
+		// Ignoring errors here, these exist. ReadDir sorts by name.
+		files, _ := config.ReadDir("config")
+		for _, f := range files {
+			b, _ := config.ReadFile("config/" + f.Name())
+			fmt.Fprintf(h, "%s\x00%d\x00", f.Name(), len(b))
+			h.Write(b)
+		}
+		contextHashCache = hex.EncodeToString(h.Sum(nil))
+	}
+	return contextHashCache
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+func TestImageFor(t *testing.T) {
+	root := t.TempDir()
+	p := &project.Project{Root: root, Src: filepath.Join(root, "src"), Godot: &godot.Official{}}
+
+	dockerfile := filepath.Join(root, "android.Dockerfile")
+	content := "FROM starriver.run/gobbo:android AS android\nFROM starriver.run/gobbo:latest\n"
+	if err := os.WriteFile(dockerfile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p.Export.Images = map[string]*project.Image{"android": {Dockerfile: dockerfile}}
+	variant := &project.Variant{Image: &project.Image{Image: "example/steam"}}
+
+	tests := []struct {
+		variant  *project.Variant
+		platform string
+		mono     bool
+		expected string
+	}{
+		{nil, "Linux", false, imageRepo + ":base-" + ImageVersion},
+		{nil, "Windows Desktop", false, imageRepo + ":windows-" + ImageVersion},
+		{nil, "Windows Desktop", true, imageRepo + ":windows-mono-" + ImageVersion},
+		{nil, "Web", true, imageRepo + ":mono-" + ImageVersion},
+		{nil, "Android", false, projectImagePrefix},
+		{variant, "Android", false, "example/steam"},
+	}
+
+	for _, test := range tests {
+		c := &ComposeConfig{}
+		p.Godot.Mono = test.mono
+		tag, err := c.imageFor(p, test.variant, test.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(tag, test.expected) {
+			t.Errorf("%s: expected %s, got %s", test.platform, test.expected, tag)
+		}
+	}
+
+	// The project image's bases are built too.
+	c := &ComposeConfig{}
+	p.Godot.Mono = false
+	tag, _ := c.imageFor(p, nil, "Android")
+	names := []string{}
+	for other, build := range c.images {
+		if other != tag {
+			names = append(names, build.name)
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"android", "base"}) {
+		t.Errorf("Expected android & base images, got %v", names)
+	}
+}
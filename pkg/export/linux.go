@@ -0,0 +1,234 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Linux packaging, performed by command.sh after the export. The desktop
+// entry, .deb control file & Flatpak manifest are generated here, and passed
+// to the container in the environment.
+
+const linuxPlatform = "Linux"
+
+type linuxArch struct {
+	deb      string
+	appImage string
+}
+
+// Godot's binary_format/architecture values.
+var linuxArchs = map[string]linuxArch{
+	"x86_64": {"amd64", "x86_64"},
+	"x86_32": {"i386", "i686"},
+	"arm64":  {"arm64", "aarch64"},
+	"arm32":  {"armhf", "armhf"},
+	"rv64":   {"riscv64", "riscv64"},
+}
+
+var debVersionRe = regexp.MustCompile(`[^A-Za-z0-9.+~]`)
+
+// Configure packaging for a Linux service.
+func addLinux(s *Service, p *project.Project, linux *project.Linux) error {
+	if linux == nil || s.Environment.ExportPlatform != linuxPlatform {
+		return nil
+	}
+	if !linux.AppImage && !linux.Deb && !linux.Flatpak {
+		return nil
+	}
+
+	env := &s.Environment
+	preset := env.ExportPreset
+
+	name := p.Name
+	pkg := slug.Make(name)
+	if pkg == "" {
+		return fmt.Errorf("%s: can't derive a package name from the project's name", preset)
+	}
+
+	desktopID := pkg
+	if linux.AppID != "" {
+		desktopID = linux.AppID
+	}
+
+	var icon, iconSize string
+	if p.Icon != "" {
+		icon = "/srv/src/" + strings.TrimPrefix(p.Icon, "res://")
+		switch path.Ext(icon) {
+		case ".svg":
+			iconSize = "scalable"
+		case ".png":
+			iconSize = "256x256"
+		default:
+			return fmt.Errorf("%s: project icon must be an SVG or PNG for Linux packaging", preset)
+		}
+	} else if linux.AppImage {
+		return fmt.Errorf("%s: AppImages need a project icon (application/config/icon)", preset)
+	}
+
+	arch, err := presetArch(p, p.Preset(preset), p.Export.Variants[env.ExportVariant])
+	if err != nil {
+		return err
+	}
+
+	env.set("LINUX_PACKAGE", "1")
+	env.set("LINUX_APPIMAGE", boolEnv(linux.AppImage))
+	env.set("LINUX_DEB", boolEnv(linux.Deb))
+	env.set("LINUX_FLATPAK", boolEnv(linux.Flatpak))
+	env.set("LINUX_NAME", pkg)
+	env.set("LINUX_DESKTOP_ID", desktopID)
+	env.set("LINUX_ICON", icon)
+	env.set("LINUX_ICON_SIZE", iconSize)
+	env.set("LINUX_APPIMAGE_ARCH", arch.appImage)
+	env.set("LINUX_DESKTOP_ENTRY", desktopEntry(name, pkg, desktopID, icon != "", linux.Categories))
+
+	if linux.Deb {
+		env.set("LINUX_DEB_CONTROL", debControl(
+			name, pkg, p.Version, arch.deb, linux.Maintainer,
+		))
+	}
+	if linux.Flatpak {
+		manifest, err := flatpakManifest(pkg, linux.AppID, path.Ext(icon), iconSize)
+		if err != nil {
+			return err
+		}
+		env.set("LINUX_FLATPAK_MANIFEST", manifest)
+	}
+
+	return nil
+}
+
+// The architecture of a Linux preset's exports, taking the variant's overrides
+// into account. variant may be nil.
+func presetArch(p *project.Project, pr *project.Preset, variant *project.Variant) (linuxArch, error) {
+	const key = "binary_format/architecture"
+	preset := pr.Name
+
+	var value string
+	if variant != nil {
+		value = presetOptions(variant, preset)[key]
+	}
+	if value == "" {
+		options := pr.Section + ".options"
+		cfg, err := godot.Query(p.ExportPresetsPath(), godot.Q{options: {key}})
+		if err != nil {
+			return linuxArch{}, err
+		}
+		value = cfg[options][key]
+	}
+
+	name := godot.Unquote(value)
+	if name == "" {
+		name = "x86_64"
+	}
+	arch, ok := linuxArchs[name]
+	if !ok {
+		return linuxArch{}, fmt.Errorf("%s: can't package architecture '%s'", preset, name)
+	}
+	if arch.appImage != "x86_64" && arch.appImage != "aarch64" {
+		glog.Warnf("%s: AppImages may not be supported on '%s'", preset, name)
+	}
+	return arch, nil
+}
+
+func desktopEntry(name, exec, id string, icon bool, categories []string) string {
+	lines := []string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=" + name,
+		"Exec=" + exec,
+	}
+	if icon {
+		lines = append(lines, "Icon="+id)
+	}
+	lines = append(lines,
+		"Categories="+strings.Join(categories, ";")+";",
+		"Terminal=false",
+	)
+	return strings.Join(lines, "\n")
+}
+
+func debControl(name, pkg, version, arch, maintainer string) string {
+	// Debian versions must start with a digit, and can't contain most
+	// punctuation.
+	version = debVersionRe.ReplaceAllString(version, ".")
+	if version == "" {
+		version = "0"
+	} else if version[0] < '0' || version[0] > '9' {
+		version = "0~" + version
+	}
+
+	return strings.Join([]string{
+		"Package: " + pkg,
+		"Version: " + version,
+		"Architecture: " + arch,
+		"Maintainer: " + maintainer,
+		"Section: games",
+		"Priority: optional",
+		"Description: " + name,
+	}, "\n")
+}
+
+type flatpakModule struct {
+	Name          string              `json:"name"`
+	BuildSystem   string              `json:"buildsystem"`
+	BuildCommands []string            `json:"build-commands"`
+	Sources       []map[string]string `json:"sources"`
+}
+
+type flatpak struct {
+	ID             string          `json:"id"`
+	Runtime        string          `json:"runtime"`
+	RuntimeVersion string          `json:"runtime-version"`
+	SDK            string          `json:"sdk"`
+	Command        string          `json:"command"`
+	FinishArgs     []string        `json:"finish-args"`
+	Modules        []flatpakModule `json:"modules"`
+}
+
+// A Flatpak manifest for flatpak-builder. It's written alongside the staged
+// export, which it uses as its source.
+func flatpakManifest(pkg, id, iconExt, iconSize string) (string, error) {
+	lib := "/app/lib/" + pkg
+	commands := []string{
+		fmt.Sprintf("install -Dm755 %s %s/%s", pkg, lib, pkg),
+		fmt.Sprintf("if [ -e %s.pck ]; then install -Dm644 %s.pck %s/%s.pck; fi", pkg, pkg, lib, pkg),
+		"mkdir -p /app/bin",
+		fmt.Sprintf("ln -s %s/%s /app/bin/%s", lib, pkg, pkg),
+		fmt.Sprintf("install -Dm644 %s.desktop /app/share/applications/%s.desktop", id, id),
+	}
+	if iconExt != "" {
+		commands = append(commands, fmt.Sprintf(
+			"install -Dm644 %s%s /app/share/icons/hicolor/%s/apps/%s%s",
+			id, iconExt, iconSize, id, iconExt,
+		))
+	}
+
+	b, err := json.MarshalIndent(flatpak{
+		ID:             id,
+		Runtime:        "org.freedesktop.Platform",
+		RuntimeVersion: "23.08",
+		SDK:            "org.freedesktop.Sdk",
+		Command:        pkg,
+		FinishArgs: []string{
+			"--share=ipc",
+			"--socket=x11",
+			"--socket=pulseaudio",
+			"--device=all",
+		},
+		Modules: []flatpakModule{{
+			Name:          pkg,
+			BuildSystem:   "simple",
+			BuildCommands: commands,
+			Sources:       []map[string]string{{"type": "dir", "path": "."}},
+		}},
+	}, "", "  ")
+	return string(b), err
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDebControlVersion(t *testing.T) {
+	for version, expected := range map[string]string{
+		"":           "0",
+		"1.2.3":      "1.2.3",
+		"v1.2":       "0~v1.2",
+		"1.0 beta_2": "1.0.beta.2",
+	} {
+		control := debControl("My Game", "my-game", version, "amd64", "Me <me@example.com>")
+		if !strings.Contains(control, "\nVersion: "+expected+"\n") {
+			t.Errorf("%q: got control:\n%s", version, control)
+		}
+	}
+}
+
+func TestFlatpakManifest(t *testing.T) {
+	manifest, err := flatpakManifest("my-game", "com.example.MyGame", ".svg", "scalable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var f flatpak
+	if err = json.Unmarshal([]byte(manifest), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != "com.example.MyGame" || f.Command != "my-game" {
+		t.Errorf("Got %+v", f)
+	}
+
+	commands := f.Modules[0].BuildCommands
+	icon := "install -Dm644 com.example.MyGame.svg /app/share/icons/hicolor/scalable/apps/com.example.MyGame.svg"
+	if commands[len(commands)-1] != icon {
+		t.Errorf("Got build commands %v", commands)
+	}
+}
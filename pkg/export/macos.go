@@ -0,0 +1,28 @@
+package export
+
+import "github.com/starriver/gobbo/pkg/project"
+
+// macOS packaging, performed by command.sh after the export. Godot can only
+// export macOS builds as zipped .app bundles on Linux, so the bundle is
+// unpacked, ad-hoc codesigned with rcodesign, rezipped & optionally put in a
+// DMG.
+
+const macOSPlatform = "macOS"
+
+// Configure packaging for a macOS service.
+func addMacOS(s *Service, p *project.Project, macos *project.MacOS) {
+	if macos == nil || s.Environment.ExportPlatform != macOSPlatform {
+		return
+	}
+
+	volume := macos.VolumeName
+	if volume == "" {
+		volume = p.Name
+	}
+
+	env := &s.Environment
+	env.set("MACOS_PACKAGE", "1")
+	env.set("MACOS_CODESIGN", boolEnv(macos.Codesign))
+	env.set("MACOS_DMG", boolEnv(macos.DMG))
+	env.set("MACOS_VOLUME_NAME", volume)
+}
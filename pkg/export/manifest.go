@@ -0,0 +1,189 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Machine-readable record of everything an export run produced, written to
+// dist/manifest.json.
+
+const ManifestName = "manifest.json"
+
+type Manifest struct {
+	Artifacts []Artifact `json:"artifacts"`
+	// Input fingerprints of each successfully built cell, keyed by service
+	// name. See Fingerprints().
+	Cells map[string]string `json:"cells"`
+}
+
+type Artifact struct {
+	// Relative to dist, with forward slashes.
+	Path           string    `json:"path"`
+	Cell           string    `json:"cell"`
+	Preset         string    `json:"preset"`
+	Platform       string    `json:"platform"`
+	Variant        string    `json:"variant,omitempty"`
+	Debug          bool      `json:"debug"`
+	GodotVersion   string    `json:"godot_version"`
+	ProjectVersion string    `json:"project_version,omitempty"`
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256"`
+	Built          time.Time `json:"built"`
+}
+
+// Collect the artifacts in each service's dist directory. Services whose
+// directories don't exist (eg. because their container failed) are skipped.
+func NewManifest(c *ComposeConfig, dist string) (*Manifest, error) {
+	m := &Manifest{
+		Artifacts: []Artifact{},
+		Cells:     map[string]string{},
+	}
+
+	for name, s := range c.Services {
+		dir := s.Dist()
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			a, err := newArtifact(path, &s.Environment)
+			if err != nil {
+				return err
+			}
+			a.Cell = name
+			m.Artifacts = append(m.Artifacts, a)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.relativize(dist); err != nil {
+		return nil, err
+	}
+	m.sort()
+
+	return m, nil
+}
+
+// Read dist/manifest.json. Returns nil if it doesn't exist.
+func ReadManifest(dist string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dist, ManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("'%s': %v", ManifestName, err)
+	}
+	return m, nil
+}
+
+// Record fingerprints for the cells that produced artifacts, and carry over
+// the artifacts & fingerprints of skipped cells from the previous manifest.
+func (m *Manifest) Record(fps map[string]string, prev *Manifest, skipped []string) {
+	for _, a := range m.Artifacts {
+		if fp, ok := fps[a.Cell]; ok {
+			m.Cells[a.Cell] = fp
+		}
+	}
+
+	if prev == nil {
+		return
+	}
+
+	for _, a := range prev.Artifacts {
+		if slices.Contains(skipped, a.Cell) {
+			m.Artifacts = append(m.Artifacts, a)
+		}
+	}
+	for _, name := range skipped {
+		m.Cells[name] = prev.Cells[name]
+	}
+	m.sort()
+}
+
+func (m *Manifest) sort() {
+	slices.SortFunc(m.Artifacts, func(a, b Artifact) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+func newArtifact(path string, env *Environment) (a Artifact, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return
+	}
+
+	a = Artifact{
+		Path:           path,
+		Preset:         env.ExportPreset,
+		Platform:       env.ExportPlatform,
+		Variant:        env.ExportVariant,
+		Debug:          env.ExportDebug == "1",
+		GodotVersion:   env.GodotVersion,
+		ProjectVersion: env.ProjectVersion,
+		Size:           st.Size(),
+		SHA256:         hex.EncodeToString(hash.Sum(nil)),
+		Built:          st.ModTime().UTC(),
+	}
+	return
+}
+
+// Artifact paths are absolute until written.
+func (m *Manifest) relativize(dist string) error {
+	for i := range m.Artifacts {
+		rel, err := filepath.Rel(dist, m.Artifacts[i].Path)
+		if err != nil {
+			return err
+		}
+		m.Artifacts[i].Path = filepath.ToSlash(rel)
+	}
+	return nil
+}
+
+// Write the manifest to dist/manifest.json, replacing any previous one.
+func (m *Manifest) Write(dist string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(
+		filepath.Join(dist, ManifestName),
+		append(b, '\n'), // POSIX
+		0o644,
+	)
+}
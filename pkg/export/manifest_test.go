@@ -0,0 +1,75 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+func TestManifest(t *testing.T) {
+	dist := t.TempDir()
+
+	service := func(dir string, env Environment) Service {
+		volumes := make([]project.Volume, distVolume+1)
+		volumes[distVolume].Source = filepath.Join(dist, dir)
+		return Service{Volumes: volumes, Environment: env}
+	}
+
+	c := &ComposeConfig{
+		Services: map[string]Service{
+			"steam_linux": service("steam/linux", Environment{
+				ExportPreset:   "Linux",
+				ExportPlatform: "Linux",
+				ExportVariant:  "steam",
+				ExportDebug:    "1",
+				GodotVersion:   "4.3",
+			}),
+			// Never produced anything.
+			"steam_web": service("steam/web", Environment{}),
+		},
+	}
+
+	os.MkdirAll(filepath.Join(dist, "steam", "linux"), 0o755)
+	err := os.WriteFile(
+		filepath.Join(dist, "steam", "linux", "game"),
+		[]byte("hello"),
+		0o644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewManifest(c, dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Artifacts) != 1 {
+		t.Fatalf("Got %d artifacts, expected 1", len(m.Artifacts))
+	}
+
+	a := m.Artifacts[0]
+	if a.Path != "steam/linux/game" {
+		t.Errorf("Got path '%s'", a.Path)
+	}
+	if a.Variant != "steam" || !a.Debug || a.GodotVersion != "4.3" {
+		t.Errorf("Got unexpected artifact %+v", a)
+	}
+	if a.Size != 5 {
+		t.Errorf("Got size %d, expected 5", a.Size)
+	}
+	// sha256("hello")
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if a.SHA256 != expected {
+		t.Errorf("Got hash %s, expected %s", a.SHA256, expected)
+	}
+
+	if err = m.Write(dist); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dist, ManifestName)); err != nil {
+		t.Error(err)
+	}
+}
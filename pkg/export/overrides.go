@@ -0,0 +1,206 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/godot"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Variant overrides of project.godot & export_presets.cfg. Patched copies of
+// the files are written to dist/.overrides, and mounted into the container,
+// where command.sh copies them over the writable source.
+
+const (
+	OverridesDir    = ".overrides"
+	overridesTarget = "/srv/overrides"
+)
+
+// Mount a variant's overrides for a service. They're written by
+// WriteOverrides(), so that configuring has no side effects.
+func addOverrides(s *Service, p *project.Project, variant *project.Variant) {
+	if !hasOverrides(variant, s.Environment.ExportPreset) {
+		return
+	}
+
+	s.Volumes = append(s.Volumes, project.Volume{
+		Type:     "bind",
+		Source:   overridesDir(p, &s.Environment),
+		Target:   overridesTarget,
+		ReadOnly: true,
+	})
+}
+
+func hasOverrides(variant *project.Variant, preset string) bool {
+	if variant == nil {
+		return false
+	}
+	return len(variant.Settings) != 0 || len(variant.Features) != 0 ||
+		len(presetOptions(variant, preset)) != 0
+}
+
+func overridesDir(p *project.Project, env *Environment) string {
+	return filepath.Join(
+		p.Export.Dist,
+		OverridesDir,
+		slug.Make(env.ExportVariant),
+		slug.Make(env.ExportPreset),
+	)
+}
+
+// Write the overrides mounted by each service.
+func WriteOverrides(c *ComposeConfig, p *project.Project) error {
+	for _, s := range c.Services {
+		variant := p.Export.Variants[s.Environment.ExportVariant]
+		if !hasOverrides(variant, s.Environment.ExportPreset) {
+			continue
+		}
+		if err := writeOverrides(&s.Environment, p, variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeOverrides(env *Environment, p *project.Project, variant *project.Variant) error {
+	dir := overridesDir(p, env)
+
+	// Start afresh, so stale overrides aren't applied.
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	if len(variant.Settings) != 0 {
+		err := patchFile(p.GodotConfigPath(), dir, func(content string) string {
+			// Sorted, so the output is stable.
+			paths := make([]string, 0, len(variant.Settings))
+			for path := range variant.Settings {
+				paths = append(paths, path)
+			}
+			slices.Sort(paths)
+
+			for _, path := range paths {
+				section, key := project.SplitSetting(path)
+				content = godot.SetKey(content, section, key, variant.Settings[path])
+			}
+			return content
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(variant.Features) != 0 {
+		pr := p.Preset(env.ExportPreset)
+		ep, err := godot.Query(p.ExportPresetsPath(), godot.Q{
+			pr.Section: {"custom_features"},
+		})
+		if err != nil {
+			return err
+		}
+
+		features := []string{}
+		if existing := godot.Unquote(ep[pr.Section]["custom_features"]); existing != "" {
+			features = strings.Split(existing, ",")
+		}
+		for _, f := range variant.Features {
+			if !slices.Contains(features, f) {
+				features = append(features, f)
+			}
+		}
+
+		value, _ := godot.Encode(strings.Join(features, ","))
+		err = patchFile(p.ExportPresetsPath(), dir, func(content string) string {
+			return godot.SetKey(content, pr.Section, "custom_features", value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if options := presetOptions(variant, env.ExportPreset); len(options) != 0 {
+		pr := p.Preset(env.ExportPreset)
+		if err := patchPresetOptions(p, pr, dir, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keys in [preset.N] rather than [preset.N.options], in case they're missing
+// from the file.
+var presetKeys = []string{
+	"custom_features",
+	"dedicated_server",
+	"encrypt_directory",
+	"encrypt_pck",
+	"encryption_exclude_filters",
+	"encryption_include_filters",
+	"exclude_filter",
+	"export_filter",
+	"export_path",
+	"include_filter",
+	"runnable",
+	"script_export_mode",
+}
+
+// A variant's option overrides for a preset, whether keyed by name or slug.
+func presetOptions(variant *project.Variant, preset string) map[string]string {
+	options := map[string]string{}
+	for _, k := range []string{slug.Make(preset), preset} {
+		for option, value := range variant.Presets[k] {
+			options[option] = value
+		}
+	}
+	return options
+}
+
+// Override a preset's options. Most are in [preset.N.options], but some (eg.
+// include_filter) are in [preset.N] itself.
+func patchPresetOptions(p *project.Project, pr *project.Preset, dir string, options map[string]string) error {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	ep, err := godot.Query(p.ExportPresetsPath(), godot.Q{pr.Section: keys})
+	if err != nil {
+		return err
+	}
+
+	return patchFile(p.ExportPresetsPath(), dir, func(content string) string {
+		for _, k := range keys {
+			section := pr.Section + ".options"
+			if _, ok := ep[pr.Section][k]; ok || slices.Contains(presetKeys, k) {
+				section = pr.Section
+			}
+			content = godot.SetKey(content, section, k, options[k])
+		}
+		return content
+	})
+}
+
+// Write a patched copy of a file into dir. If dir already has a copy, that's
+// patched instead.
+func patchFile(path, dir string, patch func(string) string) error {
+	dest := filepath.Join(dir, filepath.Base(path))
+	src := dest
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		src = path
+	}
+
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, []byte(patch(string(b))), 0o644)
+}
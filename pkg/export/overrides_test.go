@@ -0,0 +1,74 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+func TestOverrides(t *testing.T) {
+	root := t.TempDir()
+	p := &project.Project{Src: filepath.Join(root, "src")}
+	p.Export.Dist = filepath.Join(root, "dist")
+	p.Export.Presets = []project.Preset{{Name: "Windows Desktop", Section: "preset.0"}}
+
+	os.MkdirAll(p.Src, 0o755)
+	files := map[string]string{
+		"project.godot":      "[application]\n\nconfig/name=\"Game\"\n",
+		"export_presets.cfg": "[preset.0]\n\nname=\"Windows Desktop\"\ncustom_features=\"steam\"\n\n[preset.0.options]\n\napplication/company_name=\"\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(p.Src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	variant := &project.Variant{
+		Settings: map[string]string{"application/config/name": `"Game (Demo)"`},
+		Features: []string{"demo", "steam"},
+		Presets: map[string]map[string]string{
+			"windows-desktop": {
+				"application/company_name": `"Studio"`,
+				"include_filter":           `"*.json"`,
+			},
+		},
+	}
+	s := Service{Environment: Environment{ExportPreset: "Windows Desktop", ExportVariant: "demo"}}
+	p.Export.Variants = map[string]*project.Variant{"demo": variant}
+	c := &ComposeConfig{Services: map[string]Service{}}
+	addOverrides(&s, p, variant)
+	c.Services["demo_windows-desktop"] = s
+	if err := WriteOverrides(c, p); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := s.Volumes[len(s.Volumes)-1].Source
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if pg := read("project.godot"); !strings.Contains(pg, `config/name="Game (Demo)"`) {
+		t.Errorf("Got project.godot:\n%s", pg)
+	}
+
+	expected := `[preset.0]
+
+name="Windows Desktop"
+custom_features="steam,demo"
+include_filter="*.json"
+
+[preset.0.options]
+
+application/company_name="Studio"
+`
+	if ep := read("export_presets.cfg"); ep != expected {
+		t.Errorf("Got export_presets.cfg:\n%s", ep)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"embed"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/starriver/gobbo/pkg/glog"
@@ -14,8 +16,6 @@ import (
 
 // Very bespoke Docker Compose CLI client.
 
-const Tag = "starriver.run/gobbo:v1"
-
 //go:embed config/*
 var config embed.FS
 
@@ -53,25 +53,91 @@ func CheckEnvironment() error {
 	return nil
 }
 
-func BuildImage(always bool) error {
-	var cmd *exec.Cmd
-	if !always {
-		cmd = command("images", "-qf", "reference="+Tag)
-		output, err := cmd.Output()
+// Build the images used by c's services, unless they're up to date (or always
+// is true). Embedded images are out of date if they were built from a
+// different config/, and project images if any embedded image is rebuilt.
+// Embedded images are built first, as project Dockerfiles are likely to be
+// based on them.
+func BuildImages(c *ComposeConfig, always bool) error {
+	tags := slices.Collect(maps.Keys(c.images))
+	slices.SortFunc(tags, func(a, b string) int {
+		if (c.images[a].dockerfile == "") != (c.images[b].dockerfile == "") {
+			if c.images[a].dockerfile == "" {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	rebuilt := false
+	for _, tag := range tags {
+		build := c.images[tag]
+		embedded := build.dockerfile == ""
+
+		if !always && !(rebuilt && !embedded) {
+			exists, err := imageExists(tag)
+			if err != nil {
+				return err
+			}
+
+			if exists && embedded {
+				label, err := imageLabel(tag, contextLabel)
+				if err != nil {
+					return err
+				}
+				if label != contextHash() {
+					glog.Infof("Export image '%s' is out of date", tag)
+					exists = false
+				}
+			}
+
+			if exists {
+				glog.Debugf("Image with tag '%s' already built", tag)
+				continue
+			}
+		}
+
+		var err error
+		if embedded {
+			err = buildEmbedded(tag, build.name)
+			rebuilt = true
+		} else {
+			glog.Infof("Building project export image with tag '%s'", tag)
+			err = command("build", "-t", tag, "-f", build.dockerfile, build.context).Run()
+		}
 		if err != nil {
 			return err
 		}
+	}
 
-		if len(output) != 0 {
-			glog.Debugf(
-				"Image with tag '%s' already built: %s",
-				Tag, string(output),
-			)
-			return nil
-		}
+	return nil
+}
+
+func imageExists(tag string) (bool, error) {
+	output, err := command("images", "-qf", "reference="+tag).Output()
+	if err != nil {
+		return false, err
 	}
+	return len(output) != 0, nil
+}
+
+// Value of a label on an image. Empty if it isn't set.
+func imageLabel(tag, label string) (string, error) {
+	format := fmt.Sprintf(`{{index .Config.Labels %q}}`, label)
+	output, err := command("image", "inspect", "-f", format, tag).Output()
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "<no value>" {
+		value = ""
+	}
+	return value, nil
+}
 
-	glog.Infof("Building export image with tag '%s'", Tag)
+func buildEmbedded(tag, name string) error {
+	glog.Infof("Building export image with tag '%s'", tag)
 
 	// Create a temporary build context.
 	ctx, err := os.MkdirTemp("", "gobbo-image-build")
@@ -105,6 +171,23 @@ func BuildImage(always bool) error {
 		}
 	}
 
-	cmd = command("build", "-t", Tag, ctx)
-	return cmd.Run()
+	// Also tag it by name, for project Dockerfiles.
+	args := []string{
+		"build",
+		"-t", tag,
+		"-t", imageRepo + ":" + name,
+		"--label", contextLabel + "=" + contextHash(),
+	}
+	if name == "base" {
+		args = append(args, "-t", LatestTag)
+	}
+
+	// Mono images are layered on top of a platform image.
+	target := name
+	if platform, ok := strings.CutSuffix(name, "-mono"); ok {
+		target = "mono"
+		args = append(args, "--build-arg", "PLATFORM="+platform)
+	}
+	args = append(args, "--target", target, ctx)
+	return command(args...).Run()
 }
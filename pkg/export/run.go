@@ -1,15 +1,184 @@
 package export
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 
+	"github.com/starriver/gobbo/pkg/glog"
 	"gopkg.in/yaml.v3"
 )
 
-func Run(c *ComposeConfig) error {
-	cmd := command("compose", "-f", "-", "up")
-	cmd.Stdout = os.Stdout
+// Returned by Run() if the exports were interrupted.
+var ErrCancelled = errors.New("exports cancelled")
+
+// Each cell's output is written to dist/logs/<cell>.log.
+const LogsDir = "logs"
+
+// Number of lines kept in CellResult.Tail.
+const tailLines = 20
+
+// Godot often exits successfully despite these, so they fail the cell -
+// unless they match export.allow_errors.
+var fatalPatterns = []string{
+	"Parse Error",
+	"Failed loading resource",
+	"SCRIPT ERROR",
+}
+
+type CellResult struct {
+	Cell string
+	// Host path of the cell's log file.
+	Log string
+	// -1 if the container didn't exit.
+	ExitCode  int
+	Cancelled bool
+	// Counts of Godot's ERROR: & WARNING: lines.
+	Errors   int
+	Warnings int
+	// Lines matching fatalPatterns, along with their "at:" lines.
+	Fatal []string
+	// The last lines of the cell's output.
+	Tail []string
+
+	// Whether the previous line was fatal.
+	afterFatal bool
+}
+
+func (r *CellResult) Failed() bool {
+	return r.Cancelled || r.ExitCode != 0 || len(r.Fatal) != 0
+}
+
+// Run the exports with Compose. Each cell's dist directory is emptied first,
+// so only this run's outputs are collected. Each cell's output is written to
+// its log file in dist, and shown with a prefix unless quiet is true. Cells
+// whose output has fatal Godot errors fail, even if their containers exit
+// successfully.
+//
+// On SIGINT or SIGTERM, the containers are killed, and ErrCancelled is
+// returned. Either way, the dist directories of cells that failed are
+// removed, and the containers are removed afterwards. Results are sorted by
+// cell.
+func Run(c *ComposeConfig, dist string, quiet bool) (results []*CellResult, err error) {
+	if err = c.resolveSecrets(); err != nil {
+		return
+	}
+
+	logs := filepath.Join(dist, LogsDir)
+	if err = os.MkdirAll(logs, 0o755); err != nil {
+		return
+	}
+
+	// Clear outputs from previous runs, so they aren't collected.
+	for _, s := range c.Services {
+		if err = os.RemoveAll(s.Dist()); err != nil {
+			return
+		}
+		if err = os.MkdirAll(s.Dist(), 0o755); err != nil {
+			return
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	defer c.down()
+	if err = c.up(); err != nil {
+		select {
+		case <-sigs:
+			err = ErrCancelled
+		default:
+		}
+		return
+	}
+
+	names := slices.Sorted(maps.Keys(c.Services))
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		r := &CellResult{
+			Cell: name,
+			Log:  filepath.Join(logs, name+".log"),
+		}
+		results = append(results, r)
+
+		prefix := fmt.Sprintf("%-*s", width, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.follow(r, func(line string) {
+				if !quiet {
+					glog.Output(prefix, i, line)
+				}
+			}); err != nil {
+				glog.Warnf("%s: couldn't follow logs: %v", name, err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	interrupted := false
+	select {
+	case <-done:
+	case <-sigs:
+		interrupted = true
+		glog.Warn("Cancelling exports...")
+		// Stopping the containers ends their logs.
+		if killErr := command("compose", "-p", c.name, "kill").Run(); killErr != nil {
+			glog.Warnf("Couldn't kill export containers: %v", killErr)
+		}
+		<-done
+		err = ErrCancelled
+	}
+
+	states := c.states()
+	for _, r := range results {
+		r.ExitCode = -1
+		i := slices.IndexFunc(states, func(cs containerState) bool {
+			return cs.Service == r.Cell && cs.State == "exited"
+		})
+		if i != -1 {
+			r.ExitCode = states[i].ExitCode
+		}
+		r.Cancelled = interrupted && r.ExitCode != 0
+
+		// Don't leave partial outputs to be collected.
+		if r.Failed() {
+			s := c.Services[r.Cell]
+			if rmErr := os.RemoveAll(s.Dist()); rmErr != nil {
+				glog.Warnf("Couldn't remove '%s': %v", s.Dist(), rmErr)
+			}
+		}
+	}
+
+	return
+}
+
+// Create & start the containers.
+func (c *ComposeConfig) up() error {
+	cmd := command("compose", "-p", c.name, "-f", "-", "up", "--detach")
 	reader, writer := io.Pipe()
 
 	go func() {
@@ -20,6 +189,135 @@ func Run(c *ComposeConfig) error {
 
 	cmd.Stdin = reader
 
-	err := cmd.Run()
-	return err
+	// Secrets are interpolated into the config by Compose.
+	if len(c.SecretEnv) != 0 {
+		cmd.Env = os.Environ()
+		for k, v := range c.SecretEnv {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+
+	return cmd.Run()
+}
+
+func (c *ComposeConfig) down() {
+	cmd := command("compose", "-p", c.name, "down", "--remove-orphans")
+	if err := cmd.Run(); err != nil {
+		glog.Warnf("Couldn't remove export containers: %v", err)
+	}
+}
+
+// Follow a cell's output until its container stops, writing it to the cell's
+// log file and passing each line to show.
+func (c *ComposeConfig) follow(r *CellResult, show func(string)) error {
+	f, err := os.Create(r.Log)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := command("compose", "-p", c.name, "logs", "--follow", "--no-color", "--no-log-prefix", r.Cell)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	detach(cmd)
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- cmd.Wait()
+		writer.Close()
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(f, line)
+		r.count(line, c.allowErrors)
+		show(line)
+	}
+	if err = scanner.Err(); err != nil {
+		// Let logs finish regardless.
+		io.Copy(io.Discard, reader)
+		return err
+	}
+	return <-waited
+}
+
+// Count a line of output, check it for fatal errors, and add it to the tail.
+func (r *CellResult) count(line string, allow []*regexp.Regexp) {
+	switch {
+	case strings.HasPrefix(line, "ERROR:"):
+		r.Errors++
+	case strings.HasPrefix(line, "WARNING:"):
+		r.Warnings++
+	}
+
+	if at, ok := strings.CutPrefix(strings.TrimSpace(line), "at:"); ok && r.afterFatal {
+		// Godot follows errors with their location.
+		r.Fatal[len(r.Fatal)-1] += " (at" + at + ")"
+	}
+	r.afterFatal = isFatal(line, allow)
+	if r.afterFatal {
+		r.Fatal = append(r.Fatal, line)
+	}
+
+	r.Tail = append(r.Tail, line)
+	if len(r.Tail) > tailLines {
+		r.Tail = r.Tail[1:]
+	}
+}
+
+func isFatal(line string, allow []*regexp.Regexp) bool {
+	if !slices.ContainsFunc(fatalPatterns, func(p string) bool {
+		return strings.Contains(line, p)
+	}) {
+		return false
+	}
+	return !slices.ContainsFunc(allow, func(re *regexp.Regexp) bool {
+		return re.MatchString(line)
+	})
+}
+
+type containerState struct {
+	Service  string
+	State    string
+	ExitCode int
+}
+
+func (c *ComposeConfig) states() []containerState {
+	output, err := command("compose", "-p", c.name, "ps", "-a", "--format", "json").Output()
+	if err != nil {
+		glog.Warnf("Couldn't check export containers: %v", err)
+	}
+	return parseContainerStates(output)
+}
+
+// Parse the output of compose ps --format json. Depending on the version of
+// Compose, it's either a JSON array, or an object per line.
+func parseContainerStates(output []byte) []containerState {
+	states := []containerState{}
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+
+		if raw[0] == '[' {
+			var a []containerState
+			if json.Unmarshal(raw, &a) == nil {
+				states = append(states, a...)
+			}
+		} else {
+			var cs containerState
+			if json.Unmarshal(raw, &cs) == nil {
+				states = append(states, cs)
+			}
+		}
+	}
+	return states
 }
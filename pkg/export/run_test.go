@@ -0,0 +1,62 @@
+package export
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestParseContainerStates(t *testing.T) {
+	lines := `{"Service":"linux","State":"exited","ExitCode":0}
+{"Service":"web","State":"running","ExitCode":0}
+`
+	array := `[{"Service":"linux","State":"exited","ExitCode":0},{"Service":"web","State":"running","ExitCode":0}]`
+
+	for _, output := range []string{lines, array} {
+		states := parseContainerStates([]byte(output))
+		if len(states) != 2 || states[0].Service != "linux" || states[1].State != "running" {
+			t.Errorf("Got %+v", states)
+		}
+	}
+}
+
+func TestCellResultCount(t *testing.T) {
+	r := &CellResult{}
+	r.count("ERROR: Cannot open file.", nil)
+	r.count("   at: something", nil)
+	r.count("WARNING: deprecated", nil)
+	for range tailLines {
+		r.count("line", nil)
+	}
+
+	if r.Errors != 1 || r.Warnings != 1 {
+		t.Errorf("Expected 1 error & 1 warning, got %d & %d", r.Errors, r.Warnings)
+	}
+	if len(r.Tail) != tailLines || r.Tail[0] != "line" {
+		t.Errorf("Got tail %v", r.Tail)
+	}
+}
+
+func TestCellResultFatal(t *testing.T) {
+	allow := []*regexp.Regexp{regexp.MustCompile(`res://addons/`)}
+	lines := []string{
+		"SCRIPT ERROR: Parse Error: Identifier \"foo\" not declared in the current scope.",
+		"   at: GDScript::reload (res://main.gd:5)",
+		"ERROR: Failed loading resource: res://addons/plugin/icon.png.",
+		"   at: _load (core/io/resource_loader.cpp:274)",
+		"Exporting...",
+	}
+
+	r := &CellResult{}
+	for _, line := range lines {
+		r.count(line, allow)
+	}
+
+	expected := []string{lines[0] + " (at GDScript::reload (res://main.gd:5))"}
+	if !slices.Equal(r.Fatal, expected) {
+		t.Errorf("Got %q", r.Fatal)
+	}
+	if !r.Failed() {
+		t.Error("Expected the cell to fail")
+	}
+}
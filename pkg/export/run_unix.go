@@ -0,0 +1,13 @@
+//go:build unix
+
+package export
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Keep Ctrl-C in the terminal from reaching cmd - Run() handles it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
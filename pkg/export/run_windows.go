@@ -0,0 +1,13 @@
+package export
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Keep Ctrl-C in the console from reaching cmd - Run() handles it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Secrets from [export.secrets], injected into services as Compose secrets.
+// They're mounted in containers at /run/secrets/<name>.
+
+const SecretsRoot = "/run/secrets"
+
+type Secret struct {
+	File        string `yaml:",omitempty"`
+	Environment string `yaml:",omitempty"`
+}
+
+// Resolve a secret's value on the host. File secrets aren't read - they're
+// mounted directly.
+func resolveSecret(s *project.Secret) (string, error) {
+	switch s.Source {
+	case project.SecretEnv:
+		v, ok := os.LookupEnv(s.Value)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' isn't set", s.Value)
+		}
+		return v, nil
+
+	case project.SecretPass, project.SecretGopass:
+		glog.Debugf("Reading secret '%s' from %s", s.Name, s.Source)
+		cmd := exec.Command(string(s.Source), "show", s.Value)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s show '%s': %v", s.Source, s.Value, err)
+		}
+		return strings.TrimSuffix(string(output), "\n"), nil
+
+	case project.SecretDotenv:
+		env, err := readDotenv(s.Value)
+		if err != nil {
+			return "", err
+		}
+		v, ok := env[s.Key]
+		if !ok {
+			return "", fmt.Errorf("'%s' isn't set in '%s'", s.Key, s.Value)
+		}
+		return v, nil
+	}
+
+	panic([]any{"Unhandled secret source", s.Source})
+}
+
+// Minimal dotenv parser: KEY=VALUE lines, optionally prefixed with 'export'.
+// Values may be single- or double-quoted. Blank lines & comments are skipped.
+func readDotenv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		t := strings.TrimSpace(scanner.Text())
+		if t == "" || t[0] == '#' {
+			continue
+		}
+		t = strings.TrimPrefix(t, "export ")
+
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' line %d: expected KEY=VALUE", path, n)
+		}
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		env[k] = v
+	}
+
+	return env, scanner.Err()
+}
+
+// Whether a secret should be available to a cell.
+func secretApplies(s *project.Secret, preset, variant string) bool {
+	if len(s.Only) != 0 &&
+		!slices.Contains(s.Only, preset) &&
+		!slices.Contains(s.Only, slug.Make(preset)) {
+		return false
+	}
+	if len(s.Variants) != 0 && !slices.Contains(s.Variants, variant) {
+		return false
+	}
+	return true
+}
+
+// Add the relevant secrets to a service.
+func (c *ComposeConfig) addSecrets(s *Service, p *project.Project) {
+	for i := range p.Export.Secrets {
+		secret := &p.Export.Secrets[i]
+		env := &s.Environment
+		if secretApplies(secret, env.ExportPreset, env.ExportVariant) {
+			c.useSecret(s, secret)
+		}
+	}
+}
+
+// Add a secret to a service (if it isn't already), declaring it on the config.
+// Its value isn't resolved until resolveSecrets().
+func (c *ComposeConfig) useSecret(s *Service, secret *project.Secret) {
+	if _, ok := c.Secrets[secret.Name]; !ok {
+		decl := Secret{}
+		if secret.Source == project.SecretFile {
+			decl.File = secret.Value
+		} else {
+			decl.Environment = secret.Variable()
+		}
+
+		if c.Secrets == nil {
+			c.Secrets = map[string]Secret{}
+			c.secretSources = map[string]*project.Secret{}
+		}
+		c.Secrets[secret.Name] = decl
+		c.secretSources[secret.Name] = secret
+	}
+
+	if !slices.Contains(s.Secrets, secret.Name) {
+		s.Secrets = append(s.Secrets, secret.Name)
+	}
+}
+
+// Check file secrets exist, and read the other secrets' values into
+// SecretEnv. This may run pass or gopass, so it's deferred until the secrets
+// are actually needed. Only the first call does anything.
+func (c *ComposeConfig) resolveSecrets() error {
+	if c.secretsResolved {
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.secretSources)) {
+		secret := c.secretSources[name]
+		if secret.Source == project.SecretFile {
+			if _, err := os.Stat(secret.Value); err != nil {
+				return fmt.Errorf("secret '%s': %v", name, err)
+			}
+			continue
+		}
+
+		v, err := resolveSecret(secret)
+		if err != nil {
+			return fmt.Errorf("secret '%s': %v", name, err)
+		}
+		c.setSecretEnv(c.Secrets[name].Environment, v)
+	}
+
+	c.secretsResolved = true
+	return nil
+}
+
+// Path of a secret in containers.
+func secretPath(name string) string {
+	return SecretsRoot + "/" + name
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDotenv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# Comment
+STEAM_USER=bob
+
+export STEAM_PASSWORD="hunter2"
+QUOTED = 'a b'
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := readDotenv(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"STEAM_USER":     "bob",
+		"STEAM_PASSWORD": "hunter2",
+		"QUOTED":         "a b",
+		"EMPTY":          "",
+	}
+	if len(env) != len(expected) {
+		t.Errorf("Got %v, expected %v", env, expected)
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("%s: got '%s', expected '%s'", k, env[k], v)
+		}
+	}
+
+	os.WriteFile(path, []byte("nope\n"), 0o600)
+	if _, err = readDotenv(path); err == nil {
+		t.Error("Expected error for line without '='")
+	}
+}
@@ -0,0 +1,40 @@
+package export
+
+import "github.com/starriver/gobbo/pkg/project"
+
+// Windows code signing, performed by command.sh with osslsigncode after the
+// export.
+
+const windowsPlatform = "Windows Desktop"
+
+// Configure signing for a Windows service.
+func (c *ComposeConfig) addSigning(s *Service, p *project.Project, sign *project.Signing) {
+	if sign == nil || s.Environment.ExportPlatform != windowsPlatform {
+		return
+	}
+
+	env := &s.Environment
+	env.set("SIGN_WINDOWS", "1")
+
+	// Secrets were validated at load time.
+	c.useSecret(s, p.Secret(sign.Certificate))
+	env.set("SIGN_CERTIFICATE", secretPath(sign.Certificate))
+
+	if sign.Password != "" {
+		c.useSecret(s, p.Secret(sign.Password))
+		env.set("SIGN_PASSWORD", secretPath(sign.Password))
+	}
+
+	env.set("SIGN_TIMESTAMP", sign.Timestamp)
+	env.set("SIGN_DESCRIPTION", sign.Description)
+	env.set("SIGN_URL", sign.URL)
+	env.set("SIGN_EMBEDDED_PCK", boolEnv(sign.EmbeddedPCK))
+	env.set("SIGN_VERIFY", boolEnv(sign.Verify))
+}
+
+func boolEnv(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 )
@@ -116,3 +117,32 @@ func logf(level Level, format string, a ...any) {
 		fmt.Fprintln(os.Stderr, line)
 	}
 }
+
+// Colours for Output() prefixes.
+var outputColors = []color.Attribute{
+	color.FgHiBlue,
+	color.FgHiMagenta,
+	color.FgHiCyan,
+	color.FgHiYellow,
+	color.FgBlue,
+	color.FgMagenta,
+	color.FgCyan,
+	color.FgYellow,
+}
+
+// Output() may be called concurrently.
+var outputMutex sync.Mutex
+
+// Log a line of a subprocess's output at the info level, prefixed with its
+// name. i picks the prefix's colour, so each subprocess can have its own.
+func Output(name string, i int, line string) {
+	if LevelInfo > CurrentLevel {
+		return
+	}
+
+	prefix := color.New(outputColors[i%len(outputColors)]).Sprint(name)
+
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprintf(os.Stderr, "%s | %s\n", prefix, line)
+}
@@ -0,0 +1,57 @@
+package godot
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// .NET SDK checks for Godot's .NET (_mono) builds.
+
+// The minimum .NET SDK major version needed by this version of Godot.
+func (g *Official) DotnetVersion() int {
+	if g.Minor >= 4 {
+		return 8
+	}
+	return 6
+}
+
+// Check that a .NET SDK new enough for this version of Godot is installed.
+func (g *Official) CheckDotnet() error {
+	output, err := exec.Command("dotnet", "--list-sdks").Output()
+	if err != nil {
+		return fmt.Errorf(
+			"Godot %s needs the .NET SDK (%d.0 or later), but 'dotnet --list-sdks' failed: %v",
+			g.String(), g.DotnetVersion(), err,
+		)
+	}
+
+	sdks := parseSDKs(string(output))
+	if len(sdks) == 0 || slices.Max(sdks) < g.DotnetVersion() {
+		return fmt.Errorf(
+			"Godot %s needs the .NET SDK %d.0 or later, but it isn't installed",
+			g.String(), g.DotnetVersion(),
+		)
+	}
+	return nil
+}
+
+// Major versions from the output of dotnet --list-sdks, whose lines look like
+// "8.0.100 [/usr/share/dotnet/sdk]".
+func parseSDKs(output string) []int {
+	majors := []int{}
+	for _, line := range strings.Split(output, "\n") {
+		major, _, ok := strings.Cut(strings.TrimSpace(line), ".")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(major)
+		if err != nil {
+			continue
+		}
+		majors = append(majors, n)
+	}
+	return majors
+}
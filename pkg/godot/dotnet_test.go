@@ -0,0 +1,14 @@
+package godot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSDKs(t *testing.T) {
+	output := "6.0.428 [/usr/share/dotnet/sdk]\n8.0.404 [/usr/share/dotnet/sdk]\n\nnonsense\n"
+	sdks := parseSDKs(output)
+	if !slices.Equal(sdks, []int{6, 8}) {
+		t.Errorf("Expected [6 8], got %v", sdks)
+	}
+}
@@ -3,7 +3,9 @@ package godot
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/adrg/xdg"
 	"github.com/google/go-github/v63/github"
@@ -153,11 +155,33 @@ func ExportTemplatesRoot() string {
 	)
 }
 
+// Paths the Godot editor might have saved its settings to, in order of
+// preference. Godot 4.3+ includes the minor version in the filename.
+func (g *Official) EditorSettingsPaths() []string {
+	root := filepath.Join(xdg.ConfigHome, "godot")
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		dir, err := os.UserConfigDir()
+		if err == nil {
+			root = filepath.Join(dir, "Godot")
+		}
+	}
+
+	return []string{
+		filepath.Join(root, fmt.Sprintf("editor_settings-4.%d.tres", g.Minor)),
+		filepath.Join(root, "editor_settings-4.tres"),
+	}
+}
+
+// Path of the export templates for this version, eg.
+// "export_templates/4.3.stable". .NET builds' templates are suffixed with
+// ".mono" rather than "_mono".
 func (g *Official) ExportTemplatesPath() string {
-	return filepath.Join(
-		ExportTemplatesRoot(),
-		g.StringEx(true, true, true),
-	)
+	name := g.StringEx(true, true, false)
+	if g.Mono {
+		name += ".mono"
+	}
+	return filepath.Join(ExportTemplatesRoot(), name)
 }
 
 func CurrentRelease(latest bool) (*Official, error) {
@@ -0,0 +1,124 @@
+package godot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Set a key in the content of a Godot config/resource file. If the key
+// already exists in the section, its value is replaced - otherwise it's added
+// to the end of the section, which is created if necessary. value must already
+// be encoded (see Encode).
+func SetKey(content, section, key, value string) string {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	line := key + "=" + value
+	header := "[" + section + "]"
+
+	start := -1
+	for i, t := range lines {
+		if t == header {
+			start = i + 1
+			break
+		}
+	}
+	if start == -1 {
+		if len(lines) != 0 && lines[len(lines)-1] != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, header, "", line)
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	// Find the key, or the end of the section.
+	end := start
+	mapDepth := 0
+	for i := start; i < len(lines); i++ {
+		t := lines[i]
+
+		if mapDepth != 0 {
+			if t == "}" {
+				mapDepth--
+			} else if len(t) != 0 && t[len(t)-1] == '{' {
+				mapDepth++
+			}
+			end = i + 1
+			continue
+		}
+
+		if len(t) == 0 || t[0] == ';' {
+			continue
+		}
+		if t[0] == '[' {
+			break
+		}
+		end = i + 1
+
+		k, _, ok := strings.Cut(t, "=")
+		if !ok || strings.Trim(k, " ") != key {
+			if t[len(t)-1] == '{' {
+				mapDepth = 1
+			}
+			continue
+		}
+
+		// Replace this value, including any map lines that follow it.
+		n := 1
+		if t[len(t)-1] == '{' {
+			for depth := 1; depth != 0 && i+n < len(lines); n++ {
+				switch t := lines[i+n]; {
+				case t == "}":
+					depth--
+				case len(t) != 0 && t[len(t)-1] == '{':
+					depth++
+				}
+			}
+		}
+		lines = append(lines[:i+1], lines[i+n:]...)
+		lines[i] = line
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	lines = append(lines[:end], append([]string{line}, lines[end:]...)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Encode a value (as decoded from TOML) in Godot's config format. Strings,
+// integers, floats, booleans & arrays of strings are supported.
+func Encode(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return quote(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			// Keep it a float.
+			s += ".0"
+		}
+		return s, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		strs := make([]string, len(v))
+		for i, vv := range v {
+			s, ok := vv.(string)
+			if !ok {
+				return "", fmt.Errorf("expected an array of strings, got %T at index %d", vv, i)
+			}
+			strs[i] = quote(s)
+		}
+		return "PackedStringArray(" + strings.Join(strs, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func quote(s string) string {
+	return `"` + quoter.Replace(s) + `"`
+}
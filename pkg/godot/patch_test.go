@@ -0,0 +1,84 @@
+package godot
+
+import "testing"
+
+func TestSetKey(t *testing.T) {
+	content := `; Engine configuration file.
+
+config_version=5
+
+[application]
+
+config/name="Game"
+config/version="1.0"
+
+[input]
+
+ui_accept={
+"deadzone": 0.5,
+"events": []
+}
+ui_cancel={
+"deadzone": 0.5,
+"events": []
+}
+`
+
+	content = SetKey(content, "application", "config/name", `"Demo"`)
+	content = SetKey(content, "application", "config/features", `PackedStringArray("demo")`)
+	content = SetKey(content, "input", "ui_accept", `{}`)
+	content = SetKey(content, "input", "ui_select", `{}`)
+	content = SetKey(content, "demo", "enabled", `true`)
+
+	expected := `; Engine configuration file.
+
+config_version=5
+
+[application]
+
+config/name="Demo"
+config/version="1.0"
+config/features=PackedStringArray("demo")
+
+[input]
+
+ui_accept={}
+ui_cancel={
+"deadzone": 0.5,
+"events": []
+}
+ui_select={}
+
+[demo]
+
+enabled=true
+`
+	if content != expected {
+		t.Errorf("Got:\n%s", content)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, c := range []struct {
+		v        any
+		expected string
+	}{
+		{`say "hi"`, `"say \"hi\""`},
+		{int64(3), "3"},
+		{2.0, "2.0"},
+		{0.5, "0.5"},
+		{true, "true"},
+		{[]any{"a", "b"}, `PackedStringArray("a", "b")`},
+	} {
+		s, err := Encode(c.v)
+		if err != nil {
+			t.Error(err)
+		} else if s != c.expected {
+			t.Errorf("Got %s, expected %s", s, c.expected)
+		}
+	}
+
+	if _, err := Encode([]any{int64(1)}); err == nil {
+		t.Error("Expected an error for an int array")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -44,7 +45,7 @@ func Query(resourcePath string, q Q) (map[string]map[string]string, error) {
 			// Using * is much more complex. We use an array of regex to keep things
 			// relatively sane.
 			pattern := fmt.Sprintf(
-				"%s[0-9]+%s",
+				"^%s[0-9]+%s$",
 				regexp.QuoteMeta(k[:arrayIndex]),
 				regexp.QuoteMeta(k[arrayIndex+1:]),
 			)
@@ -104,6 +105,7 @@ func Query(resourcePath string, q Q) (map[string]map[string]string, error) {
 					}
 					section = qMap[a.originalKey]
 					rSection = make(map[string]string, len(section))
+					r[sectionName] = rSection
 				}
 			}
 			continue
@@ -130,7 +132,7 @@ func Query(resourcePath string, q Q) (map[string]map[string]string, error) {
 			rSection[k] = v
 
 			keysRead += 1
-			if keysRead == totalKeys {
+			if len(ar) == 0 && keysRead == totalKeys {
 				break
 			}
 		}
@@ -138,3 +140,13 @@ func Query(resourcePath string, q Q) (map[string]map[string]string, error) {
 
 	return r, nil
 }
+
+// Unquote a string value returned by Query. Values that aren't quoted strings
+// are returned as-is.
+func Unquote(v string) string {
+	s, err := strconv.Unquote(v)
+	if err != nil {
+		return v
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package godot
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Read the raw contents of each section in a Godot config/resource file, keyed
+// by section name (minus the brackets). Lines outside any section are keyed by
+// "". Unlike Query, values are left entirely unparsed.
+func Sections(resourcePath string) (map[string]string, error) {
+	f, err := os.Open(resourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := map[string]string{}
+	name := ""
+	var b strings.Builder
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		t := scanner.Text()
+
+		if len(t) > 1 && t[0] == '[' && t[len(t)-1] == ']' {
+			r[name] = b.String()
+			b.Reset()
+			name = t[1 : len(t)-1]
+			continue
+		}
+
+		b.WriteString(t)
+		b.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	r[name] = b.String()
+	return r, nil
+}
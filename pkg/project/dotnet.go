@@ -0,0 +1,26 @@
+package project
+
+import (
+	"path/filepath"
+
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+// Find the C# project file in a project's source directory, as created by the
+// Godot .NET editor. Returns its name, or an empty string if there isn't one.
+func findCSProject(src string) string {
+	// Only errors on bad patterns.
+	matches, _ := filepath.Glob(filepath.Join(src, "*.csproj"))
+	if len(matches) == 0 {
+		return ""
+	}
+	if len(matches) > 1 {
+		glog.Warnf("multiple C# projects in '%s' - using '%s'", src, filepath.Base(matches[0]))
+	}
+	return filepath.Base(matches[0])
+}
+
+// Whether the project uses C#, and needs a .NET (_mono) build of Godot.
+func (p *Project) IsCSharp() bool {
+	return p.CSProject != ""
+}
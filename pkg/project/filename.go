@@ -0,0 +1,112 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+// Export filenames, templated from export.filename. The result is used for
+// each export's main file, minus its extension.
+
+const DefaultFilename = `{{.Project}}{{with .Version}}-{{.}}{{end}}{{with .Variant}}-{{.}}{{end}}-{{.Preset}}`
+
+// Data available to export.filename.
+type FilenameData struct {
+	Project  string
+	Version  string
+	Preset   string
+	Platform string
+	Variant  string
+	Debug    bool
+	// Output of git describe, if the project is in a git repo.
+	Git string
+	// The date of the export, as YYYY-MM-DD.
+	Date string
+}
+
+var filenameFuncs = template.FuncMap{
+	"slug":    slug.Make,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+}
+
+// Example data for validating templates at load time.
+var exampleFilenameData = FilenameData{
+	Project:  "Game",
+	Version:  "1.0",
+	Preset:   "Linux",
+	Platform: "Linux",
+	Variant:  "steam",
+	Git:      "v1.0-1-gabcdef0",
+	Date:     "2000-01-01",
+}
+
+func parseFilename(text string) (*template.Template, error) {
+	tmpl, err := template.New("filename").Funcs(filenameFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Catch references to fields that don't exist, etc.
+	if _, err = executeFilename(tmpl, &exampleFilenameData); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func executeFilename(tmpl *template.Template, data *FilenameData) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSpace(b.String())
+	switch {
+	case name == "":
+		return "", fmt.Errorf("filename is empty")
+	case strings.ContainsAny(name, "/\\\x00"):
+		return "", fmt.Errorf("filename '%s' contains a path separator", name)
+	}
+	return name, nil
+}
+
+// The filename for an export, without its extension. Git & Date are filled in
+// if they aren't set.
+func (p *Project) Filename(data FilenameData) (string, error) {
+	if data.Git == "" {
+		data.Git = p.GitDescribe()
+	}
+	if data.Date == "" {
+		data.Date = time.Now().Format(time.DateOnly)
+	}
+
+	name, err := executeFilename(p.Export.Filename, &data)
+	if err != nil {
+		return "", fmt.Errorf("export.filename: %v", err)
+	}
+	return name, nil
+}
+
+// Output of git describe in the project's source directory. Empty if it isn't
+// in a git repo (or git isn't installed).
+func (p *Project) GitDescribe() string {
+	if p.gitDescribe == nil {
+		cmd := exec.Command("git", "describe", "--tags", "--always", "--dirty")
+		cmd.Dir = p.Src
+		output, err := cmd.Output()
+		if err != nil {
+			glog.Debugf("git describe failed: %v", err)
+		}
+		describe := strings.TrimSpace(string(output))
+		p.gitDescribe = &describe
+	}
+	return *p.gitDescribe
+}
@@ -0,0 +1,44 @@
+package project
+
+import "testing"
+
+func TestFilename(t *testing.T) {
+	for _, text := range []string{
+		"{{.Nope}}",
+		"{{.Project",
+		"{{.Project}}/{{.Preset}}",
+		"{{if false}}x{{end}}",
+	} {
+		if _, err := parseFilename(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+
+	p := &Project{gitDescribe: new(string)}
+	data := FilenameData{
+		Project: "My Game",
+		Version: "1.0",
+		Preset:  "Windows Desktop",
+		Variant: "steam",
+		Date:    "2024-02-29",
+	}
+
+	for text, expected := range map[string]string{
+		DefaultFilename: "My Game-1.0-steam-Windows Desktop",
+		`{{slug .Project}}_{{.Date}}{{if .Debug}}_debug{{end}}`: "my-game_2024-02-29",
+	} {
+		tmpl, err := parseFilename(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Export.Filename = tmpl
+
+		name, err := p.Filename(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected {
+			t.Errorf("%q: got '%s', expected '%s'", text, name, expected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package project
+
+import (
+	"os"
+)
+
+// Container images for exports. image & dockerfile can be set in [export],
+// in each variant, and per platform in [export.images], eg.:
+//
+//	[export.images]
+//	android = { dockerfile = "docker/android.Dockerfile" }
+//	"Windows Desktop" = "ghcr.io/example/wine:latest"
+
+type Image struct {
+	// A prebuilt image reference, used as-is.
+	Image string
+	// Or, the path of a Dockerfile to build, with the project's root directory
+	// as its context.
+	Dockerfile string
+}
+
+// Read image & dockerfile keys from a table. prefix is the table's location,
+// with a trailing dot. Returns nil if neither is set.
+func parseImage(root map[string]any, prefix, base string, pushErrorf func(string, ...any)) *Image {
+	popString := popFunc[string](root, pushErrorf)
+
+	img := &Image{}
+	img.Image, _ = popString(prefix+"image", false)
+	img.Dockerfile, _ = popString(prefix+"dockerfile", false)
+
+	return validateImage(img, prefix, base, pushErrorf)
+}
+
+// Parse [export.images]. table is the (already popped) images table. Keys are
+// platform names or slugs.
+func parsePlatformImages(table map[string]any, base string, pushErrorf func(string, ...any)) map[string]*Image {
+	images := make(map[string]*Image, len(table))
+	for platform, v := range table {
+		// Platforms may contain dots, so these can't be popped by path.
+		loc := "export.images." + platform
+
+		var img *Image
+		switch v := v.(type) {
+		case string:
+			img = &Image{Image: v}
+		case map[string]any:
+			popString := popFunc[string](v, pushErrorf)
+			img = &Image{}
+			img.Image, _ = popString("image", false)
+			img.Dockerfile, _ = popString("dockerfile", false)
+			for k := range v {
+				pushErrorf("'%s.%s': unknown key", loc, k)
+			}
+		default:
+			pushErrorf("'%s': expected image or table, got %T", loc, v)
+		}
+		delete(table, platform)
+
+		if img = validateImage(img, loc+".", base, pushErrorf); img != nil {
+			images[platform] = img
+		}
+	}
+	return images
+}
+
+func validateImage(img *Image, prefix, base string, pushErrorf func(string, ...any)) *Image {
+	if img == nil || (img.Image == "" && img.Dockerfile == "") {
+		return nil
+	}
+	if img.Image != "" && img.Dockerfile != "" {
+		pushErrorf("'%simage' and '%sdockerfile' can't both be set", prefix, prefix)
+		return nil
+	}
+
+	if img.Dockerfile != "" {
+		img.Dockerfile = resolvePath(base, img.Dockerfile)
+		if _, err := os.Stat(img.Dockerfile); err != nil {
+			pushErrorf("'%sdockerfile': %v", prefix, err)
+			return nil
+		}
+	}
+	return img
+}
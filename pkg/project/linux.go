@@ -0,0 +1,52 @@
+package project
+
+import "regexp"
+
+// Linux packaging config, in [export.linux] or [export.<variant>.linux].
+
+type Linux struct {
+	AppImage bool
+	Deb      bool
+	// Generate a Flatpak manifest, to be built with flatpak-builder.
+	Flatpak bool
+	// Reverse-DNS application ID, eg. "com.example.MyGame". Required for
+	// Flatpak.
+	AppID string
+	// Required for .deb packages, eg. "Jane Doe <jane@example.com>".
+	Maintainer string
+	// Desktop entry categories. Defaults to Game.
+	Categories []string
+}
+
+var appIDRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*(\.[A-Za-z_][A-Za-z0-9_-]*)+$`)
+
+// Returns nil if the table isn't set.
+func parseLinux(root map[string]any, location string, pushErrorf func(string, ...any)) *Linux {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	if _, ok := popTable(location, false); !ok {
+		return nil
+	}
+
+	popString := popFunc[string](root, pushErrorf)
+	popBool := popFunc[bool](root, pushErrorf)
+	popStringArray := popStringArrayFunc(root, pushErrorf)
+	key := func(k string) string { return location + "." + k }
+
+	l := &Linux{}
+	l.AppImage, _ = popBool(key("appimage"), false)
+	l.Deb, _ = popBool(key("deb"), false)
+	l.Flatpak, _ = popBool(key("flatpak"), false)
+	l.AppID, _ = popString(key("app_id"), l.Flatpak)
+	l.Maintainer, _ = popString(key("maintainer"), l.Deb)
+
+	var ok bool
+	if l.Categories, ok = popStringArray(key("categories"), false); !ok {
+		l.Categories = []string{"Game"}
+	}
+
+	if l.AppID != "" && !appIDRe.MatchString(l.AppID) {
+		pushErrorf("'%s': expected a reverse-DNS ID like 'com.example.Game', got '%s'", key("app_id"), l.AppID)
+	}
+
+	return l
+}
@@ -0,0 +1,33 @@
+package project
+
+// macOS packaging config, in [export.macos] or [export.<variant>.macos].
+
+type MacOS struct {
+	// Ad-hoc codesign the .app bundle with rcodesign.
+	Codesign bool
+	// Also package the .app bundle in a DMG.
+	DMG bool
+	// The DMG's volume name. Defaults to the project's name.
+	VolumeName string
+}
+
+// Returns nil if the table isn't set.
+func parseMacOS(root map[string]any, location string, pushErrorf func(string, ...any)) *MacOS {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	if _, ok := popTable(location, false); !ok {
+		return nil
+	}
+
+	popString := popFunc[string](root, pushErrorf)
+	popBool := popFunc[bool](root, pushErrorf)
+	key := func(k string) string { return location + "." + k }
+
+	m := &MacOS{Codesign: true}
+	if codesign, ok := popBool(key("codesign"), false); ok {
+		m.Codesign = codesign
+	}
+	m.DMG, _ = popBool(key("dmg"), false)
+	m.VolumeName, _ = popString(key("volume_name"), false)
+
+	return m
+}
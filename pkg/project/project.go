@@ -4,43 +4,84 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
+	"text/template"
 
+	"github.com/gosimple/slug"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/starriver/gobbo/pkg/glog"
 	"github.com/starriver/gobbo/pkg/godot"
 )
 
 type Project struct {
-	Godot   *godot.Official
+	Godot *godot.Official
+	// Directory containing gobbo.toml.
+	Root    string
 	Src     string
 	Name    string
 	Version string
+	// res:// path of the project's icon, if set.
+	Icon string
+	// Name of the project's .csproj file in Src, if it uses C#.
+	CSProject string
+
+	// Cached by GitDescribe().
+	gitDescribe *string
 
 	Export struct {
-		Presets  []Preset
-		Only     []string
-		Dist     string
-		Zip      bool
-		Volumes  []string
-		Scripts  Scripts
-		Variants map[string]*Variant
+		Presets      []Preset
+		Only         []string
+		Dist         string
+		Filename     *template.Template
+		Version      *Version
+		Image        *Image
+		Images       map[string]*Image
+		Zip          bool
+		AllowErrors  []*regexp.Regexp
+		Volumes      []Volume
+		MountSecrets bool
+		Secrets      []Secret
+		Sign         *Signing
+		Publish      *Publish
+		MacOS        *MacOS
+		Linux        *Linux
+		Scripts      Scripts
+		Variants     map[string]*Variant
 	}
 }
 
 type Preset struct {
 	Name     string
 	Platform string
+	// Section name in export_presets.cfg, eg. "preset.0".
+	Section string
 }
 
 type Variant struct {
-	Only     []string
-	Volumes  []string
-	Scripts  Scripts
+	Only    []string
+	Image   *Image
+	Volumes []Volume
+	Scripts Scripts
+	Sign    *Signing
+	Publish *Publish
+	Steam   *Steam
+	MacOS   *MacOS
+	Linux   *Linux
+	// Encoded project.godot values, keyed by setting path.
+	Settings map[string]string
+	// Custom feature tags to add to each preset.
+	Features []string
+	// Encoded export preset options, keyed by preset name or slug, then
+	// option.
+	Presets  map[string]map[string]string
 	Elective bool
 }
 
+// Tables in [export] that aren't variants.
+var exportTables = []string{"scripts", "secrets", "sign", "publish", "macos", "linux", "images", "version"}
+
 type Scripts struct {
 	Pre  string
 	Post string
@@ -53,7 +94,7 @@ func popFunc[T any](m map[string]any, pushErrorf func(string, ...any)) func(stri
 		segs := strings.Split(path, ".") // heh
 
 		for i, seg := range segs {
-			location := strings.Join(segs[0:i], ".")
+			location := strings.Join(segs[0:i+1], ".")
 			v, ok := mm[seg]
 			if !ok {
 				if require {
@@ -76,12 +117,11 @@ func popFunc[T any](m map[string]any, pushErrorf func(string, ...any)) func(stri
 
 			t, ok = v.(T)
 			if !ok {
-				pushErrorf("'%s': expected %T, got %T", t, v)
+				pushErrorf("'%s': expected %T, got %T", location, t, v)
+				return t, false
 			}
 			// Delete this element from its parent if it isn't a table.
-			// map[any]any is (possibly) a quicker coersive check than
-			// map[string]any?
-			if _, isTable := v.(map[any]any); !isTable {
+			if _, isTable := v.(map[string]any); !isTable {
 				delete(mm, seg)
 			}
 		}
@@ -90,6 +130,28 @@ func popFunc[T any](m map[string]any, pushErrorf func(string, ...any)) func(stri
 	}
 }
 
+// TOML arrays are decoded as []any, so pop them and check their elements.
+func popStringArrayFunc(m map[string]any, pushErrorf func(string, ...any)) func(string, bool) ([]string, bool) {
+	popArray := popFunc[[]any](m, pushErrorf)
+	return func(path string, require bool) ([]string, bool) {
+		a, ok := popArray(path, require)
+		if !ok {
+			return nil, false
+		}
+
+		strs := make([]string, 0, len(a))
+		for i, v := range a {
+			s, ok := v.(string)
+			if !ok {
+				pushErrorf("'%s[%d]': expected string, got %T", path, i, v)
+				continue
+			}
+			strs = append(strs, s)
+		}
+		return strs, true
+	}
+}
+
 func scanKeys(m map[string]any, path string) []string {
 	unknown := []string{}
 	for k, v := range m {
@@ -126,7 +188,8 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 
 	popString := popFunc[string](root, pushErrorf)
 	popBool := popFunc[bool](root, pushErrorf)
-	popStringArray := popFunc[[]string](root, pushErrorf)
+	popStringArray := popStringArrayFunc(root, pushErrorf)
+	popArray := popFunc[[]any](root, pushErrorf)
 	// popStringMap := popFunc[map[string]string](root, pushErrorf)
 
 	p = &Project{}
@@ -143,7 +206,8 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	if !ok {
 		s = "src"
 	}
-	p.Src = filepath.Join(filepath.Dir(path), s)
+	p.Root = filepath.Dir(path)
+	p.Src = filepath.Join(p.Root, s)
 	_, err = os.Stat(p.GodotConfigPath())
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -151,6 +215,8 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 		} else {
 			errs = append(errs, err)
 		}
+	} else {
+		p.CSProject = findCSProject(p.Src)
 	}
 
 	p.Export.Only, _ = popStringArray("export.only", false)
@@ -162,9 +228,47 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	p.Export.Dist = filepath.Join(filepath.Dir(path), s)
 	// Directory doesn't need to exist before export.
 
+	s, ok = popString("export.filename", false)
+	if !ok {
+		s = DefaultFilename
+	}
+	p.Export.Filename, err = parseFilename(s)
+	if err != nil {
+		pushErrorf("'export.filename': %v", err)
+	}
+
+	p.Export.Version = parseVersion(root, pushErrorf)
+
 	p.Export.Zip, _ = popBool("export.zip", false)
 
-	p.Export.Volumes, _ = popStringArray("export.volumes", false)
+	allow, _ := popStringArray("export.allow_errors", false)
+	for i, pattern := range allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			pushErrorf("'export.allow_errors[%d]': %v", i, err)
+			continue
+		}
+		p.Export.AllowErrors = append(p.Export.AllowErrors, re)
+	}
+
+	base := filepath.Dir(path)
+	p.Export.Image = parseImage(root, "export.", base, pushErrorf)
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	images, _ := popTable("export.images", false)
+	p.Export.Images = parsePlatformImages(images, base, pushErrorf)
+
+	a, _ := popArray("export.volumes", false)
+	p.Export.Volumes = parseVolumes("export.volumes", a, base, pushErrorf)
+
+	p.Export.MountSecrets, _ = popBool("export.mount_secrets", false)
+
+	secrets, _ := popTable("export.secrets", false)
+	p.Export.Secrets = parseSecrets(root, secrets, base, pushErrorf)
+
+	p.Export.Sign = parseSigning(root, "export.sign", p.Export.Secrets, pushErrorf)
+	p.Export.Publish = parsePublish(root, "export.publish", pushErrorf)
+	p.Export.MacOS = parseMacOS(root, "export.macos", pushErrorf)
+	p.Export.Linux = parseLinux(root, "export.linux", pushErrorf)
 
 	p.Export.Scripts.Pre, _ = popString("export.scripts.pre", false)
 	p.Export.Scripts.Post, _ = popString("export.scripts.post", false)
@@ -172,8 +276,14 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	// The remaining export.* keys will be read as variants.
 	popVariants := popFunc[map[string]any](root, pushErrorf)
 	variants, _ := popVariants("export", false)
+	p.Export.Variants = make(map[string]*Variant, len(variants))
 
 	for k, table := range variants {
+		if slices.Contains(exportTables, k) {
+			// Already popped above - anything left over is unknown.
+			continue
+		}
+
 		// Check this is actually a table first.
 		// This prevent error spam from the below pop calls.
 		_, ok := table.(map[string]any)
@@ -186,9 +296,24 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 		prefix := fmt.Sprintf("export.%s.", k)
 
 		v.Only, _ = popStringArray(prefix+"only", false)
-		v.Volumes, _ = popStringArray(prefix+"volumes", false)
+		v.Image = parseImage(root, prefix, base, pushErrorf)
+		a, _ := popArray(prefix+"volumes", false)
+		v.Volumes = parseVolumes(prefix+"volumes", a, base, pushErrorf)
 		v.Scripts.Pre, _ = popString(prefix+"scripts.pre", false)
 		v.Scripts.Post, _ = popString(prefix+"scripts.post", false)
+		v.Sign = parseSigning(root, prefix+"sign", p.Export.Secrets, pushErrorf)
+		v.Publish = parsePublish(root, prefix+"publish", pushErrorf)
+		v.Steam = parseSteam(root, prefix+"steam", pushErrorf)
+		v.MacOS = parseMacOS(root, prefix+"macos", pushErrorf)
+		v.Linux = parseLinux(root, prefix+"linux", pushErrorf)
+		v.Settings = parseSettings(root, prefix+"settings", pushErrorf)
+		v.Presets = parsePresetOptions(root, prefix+"presets", pushErrorf)
+		v.Features, _ = popStringArray(prefix+"features", false)
+		for _, f := range v.Features {
+			if f == "" || strings.ContainsAny(f, ", ") {
+				pushErrorf("'%sfeatures': invalid feature tag '%s'", prefix, f)
+			}
+		}
 		v.Elective, _ = popBool(prefix+"elective", false)
 
 		p.Export.Variants[k] = v
@@ -197,7 +322,7 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	// Error on remaining keys, if anything still exists that isn't an empty
 	// table (recursively).
 	unknown := scanKeys(root, "")
-	for u := range unknown {
+	for _, u := range unknown {
 		pushErrorf("'%s': unknown key", u)
 	}
 
@@ -210,6 +335,7 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 		"application": {
 			"config/name",
 			"config/version",
+			"config/icon",
 		},
 	})
 	if err != nil {
@@ -217,8 +343,9 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	} else {
 		// Must exist:
 		app := gc["application"]
-		p.Name, _ = app["config/name"]
-		p.Version, _ = app["config/version"]
+		p.Name = godot.Unquote(app["config/name"])
+		p.Version = godot.Unquote(app["config/version"])
+		p.Icon = godot.Unquote(app["config/icon"])
 	}
 
 	// Query export presets
@@ -234,7 +361,7 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	} else {
 		// export_presets.cfg exists, load it:
 		ep, err := godot.Query(epPath, godot.Q{
-			"presets.*": {
+			"preset.*": {
 				"name",
 				"platform",
 			},
@@ -244,9 +371,11 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 		} else {
 			presets := make([]Preset, len(ep))
 			i := 0
-			for _, pr := range ep {
-				presets[i].Name, _ = pr["name"]
-				presets[i].Platform, _ = pr["platform"]
+			for section, pr := range ep {
+				presets[i].Name = godot.Unquote(pr["name"])
+				presets[i].Platform = godot.Unquote(pr["platform"])
+				presets[i].Section = section
+				presetMap[presets[i].Name] = true
 				i++
 			}
 			slices.SortFunc(presets, func(a, b Preset) int {
@@ -261,8 +390,16 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 		}
 	}
 
+	for platform := range p.Export.Images {
+		if !slices.ContainsFunc(p.Export.Presets, func(pr Preset) bool {
+			return pr.Platform == platform || slug.Make(pr.Platform) == platform
+		}) {
+			glog.Warnf("export.images: no presets for platform '%s'", platform)
+		}
+	}
+
 	// If referenced presets don't exist, warn, and remove from in-memory config
-	only := make([]string, len(p.Export.Only))
+	only := make([]string, 0, len(p.Export.Only))
 	for _, preset := range p.Export.Only {
 		if _, ok := presetMap[preset]; !ok {
 			glog.Warnf("export.only: missing preset '%s'", preset)
@@ -273,7 +410,7 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 	p.Export.Only = only
 
 	for k, variant := range p.Export.Variants {
-		only := make([]string, len(variant.Only))
+		only := make([]string, 0, len(variant.Only))
 		for _, preset := range variant.Only {
 			if _, ok := presetMap[preset]; !ok {
 				glog.Warnf("export.%s.only: missing preset '%s'", k, preset)
@@ -282,11 +419,40 @@ func Load(path string, ignoreStream bool) (p *Project, errs []error) {
 			}
 		}
 		variant.Only = only
+
+		for preset := range variant.Presets {
+			if !slices.ContainsFunc(p.Export.Presets, func(pr Preset) bool {
+				return pr.Name == preset || slug.Make(pr.Name) == preset
+			}) {
+				pushErrorf("'export.%s.presets.%s': no such preset", k, preset)
+			}
+		}
 	}
 
 	return
 }
 
+// Look up a secret in [export.secrets] by name. Returns nil if it isn't
+// configured.
+func (p *Project) Secret(name string) *Secret {
+	for i := range p.Export.Secrets {
+		if p.Export.Secrets[i].Name == name {
+			return &p.Export.Secrets[i]
+		}
+	}
+	return nil
+}
+
+// Look up an export preset by name. Returns nil if it doesn't exist.
+func (p *Project) Preset(name string) *Preset {
+	for i := range p.Export.Presets {
+		if p.Export.Presets[i].Name == name {
+			return &p.Export.Presets[i]
+		}
+	}
+	return nil
+}
+
 func (p *Project) GodotConfigPath() string {
 	return filepath.Join(p.Src, "project.godot")
 }
@@ -298,3 +464,7 @@ func (p *Project) ExportPresetsPath() string {
 func (p *Project) GodotCachePath() string {
 	return filepath.Join(p.Src, ".godot")
 }
+
+func (p *Project) ExportCredentialsPath() string {
+	return filepath.Join(p.GodotCachePath(), "export_credentials.cfg")
+}
@@ -0,0 +1,54 @@
+package project
+
+import "regexp"
+
+// Publishing config, in [export.publish] or [export.<variant>.publish].
+
+type Publish struct {
+	Itch *ItchPublish
+}
+
+type ItchPublish struct {
+	// itch.io project, eg. "studio/my-game".
+	Target string
+	// Channel overrides, keyed by preset name or slug. Channels are otherwise
+	// derived from the preset's platform.
+	Channels map[string]string
+}
+
+var itchTargetRe = regexp.MustCompile(`^[A-Za-z0-9_-]+/[A-Za-z0-9_-]+$`)
+
+// Returns nil if the table isn't set.
+func parsePublish(root map[string]any, location string, pushErrorf func(string, ...any)) *Publish {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	if _, ok := popTable(location, false); !ok {
+		return nil
+	}
+
+	popString := popFunc[string](root, pushErrorf)
+	key := func(k string) string { return location + "." + k }
+
+	p := &Publish{}
+	if _, ok := popTable(key("itch"), false); ok {
+		itch := &ItchPublish{Channels: map[string]string{}}
+		itch.Target, _ = popString(key("itch.target"), true)
+		if itch.Target != "" && !itchTargetRe.MatchString(itch.Target) {
+			pushErrorf("'%s': expected 'user/game', got '%s'", key("itch.target"), itch.Target)
+		}
+
+		channels, _ := popTable(key("itch.channels"), false)
+		for preset := range channels {
+			// Presets may contain dots, so these can't be popped by path.
+			channel, ok := channels[preset].(string)
+			if !ok {
+				pushErrorf("'%s': expected string, got %T", key("itch.channels."+preset), channels[preset])
+			}
+			itch.Channels[preset] = channel
+			delete(channels, preset)
+		}
+
+		p.Itch = itch
+	}
+
+	return p
+}
@@ -0,0 +1,166 @@
+package project
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Secrets for export containers, configured in [export.secrets]. Each is a
+// table with exactly one source key, eg.:
+//
+//	steam_password = { env = "STEAM_PASSWORD" }
+//	signing_cert = { file = "~/certs/windows.p12" }
+//	notary_key = { pass = "apple/notary" }
+//	steam_user = { dotenv = "~/.config/game.env", key = "STEAM_USER" }
+
+type Secret struct {
+	Name   string
+	Source SecretSource
+	// The env var name, file path, pass/gopass entry or dotenv path,
+	// depending on Source.
+	Value string
+	// The variable to read from a dotenv file. Defaults to Name.
+	Key string
+	// Presets & variants the secret is available to. Empty means all.
+	Only     []string
+	Variants []string
+}
+
+type SecretSource string
+
+const (
+	SecretEnv    SecretSource = "env"
+	SecretFile   SecretSource = "file"
+	SecretPass   SecretSource = "pass"
+	SecretGopass SecretSource = "gopass"
+	SecretDotenv SecretSource = "dotenv"
+)
+
+var secretSources = []SecretSource{
+	SecretEnv, SecretFile, SecretPass, SecretGopass, SecretDotenv,
+}
+
+var secretNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Parse [export.secrets] from the config root. table is the (already popped)
+// secrets table.
+func parseSecrets(
+	root map[string]any,
+	table map[string]any,
+	base string,
+	pushErrorf func(string, ...any),
+) []Secret {
+	secrets := make([]Secret, 0, len(table))
+	popString := popFunc[string](root, pushErrorf)
+	popStringArray := popStringArrayFunc(root, pushErrorf)
+
+	// Sorted, so conflicting names are reported consistently.
+	variables := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(table)) {
+		v := table[name]
+		loc := "export.secrets." + name
+
+		// Invalid entries are deleted, so they aren't reported again as
+		// unknown keys.
+		if !secretNameRe.MatchString(name) {
+			pushErrorf("'%s': secret names may only contain A-Z, a-z, 0-9, '_' and '-'", loc)
+			delete(table, name)
+			continue
+		}
+		if _, ok := v.(map[string]any); !ok {
+			pushErrorf("'%s': expected secret config, got %T", loc, v)
+			delete(table, name)
+			continue
+		}
+
+		s := Secret{Name: name}
+		if other, ok := variables[s.Variable()]; ok {
+			pushErrorf(
+				"'%s': conflicts with '%s' - secret names are case-insensitive, and '-' is equivalent to '_'",
+				loc, other,
+			)
+			delete(table, name)
+			continue
+		}
+		variables[s.Variable()] = name
+
+		rel := func(k string) string { return loc + "." + k }
+
+		for _, source := range secretSources {
+			value, ok := popString(rel(string(source)), false)
+			if !ok {
+				continue
+			}
+			if s.Source != "" {
+				pushErrorf("'%s': only one of %v may be set", loc, secretSources)
+				break
+			}
+			s.Source = source
+			s.Value = value
+		}
+		if s.Source == "" {
+			pushErrorf("'%s': one of %v is required", loc, secretSources)
+			continue
+		}
+
+		s.Key, _ = popString(rel("key"), false)
+		if s.Key != "" && s.Source != SecretDotenv {
+			pushErrorf("'%s.key': only valid for dotenv secrets", loc)
+		}
+		if s.Key == "" {
+			s.Key = name
+		}
+
+		s.Only, _ = popStringArray(rel("only"), false)
+		s.Variants, _ = popStringArray(rel("variants"), false)
+
+		switch s.Source {
+		case SecretFile, SecretDotenv:
+			s.Value = resolvePath(base, s.Value)
+		}
+
+		// Dotenv files are plaintext, so make sure they won't be committed
+		// alongside the project.
+		if s.Source == SecretDotenv {
+			abs, err := filepath.Abs(s.Value)
+			absBase, baseErr := filepath.Abs(base)
+			if err == nil && baseErr == nil {
+				rel, err := filepath.Rel(absBase, abs)
+				if err == nil && !strings.HasPrefix(rel, "..") {
+					pushErrorf("'%s': dotenv file must be outside the project: '%s'", loc, s.Value)
+				}
+			}
+		}
+
+		secrets = append(secrets, s)
+	}
+
+	slices.SortFunc(secrets, func(a, b Secret) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return secrets
+}
+
+// The environment variable passing the secret's value to Compose. Names are
+// upper-cased, with '-' replaced by '_'.
+func (s *Secret) Variable() string {
+	return "GOBBO_SECRET_" + strings.ToUpper(strings.ReplaceAll(s.Name, "-", "_"))
+}
+
+// Expand a leading ~ and resolve relative paths against base.
+func resolvePath(base, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(base, path)
+	}
+	return path
+}
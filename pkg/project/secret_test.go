@@ -0,0 +1,29 @@
+package project
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSecretConflicts(t *testing.T) {
+	var errs []error
+	pushErrorf := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	table := map[string]any{
+		"api-key": map[string]any{"env": "A"},
+		"API_KEY": map[string]any{"env": "B"},
+		"token":   map[string]any{"env": "C"},
+	}
+	root := map[string]any{"export": map[string]any{"secrets": table}}
+	secrets := parseSecrets(root, table, t.TempDir(), pushErrorf)
+
+	if len(secrets) != 2 || len(errs) != 1 {
+		t.Fatalf("Expected 2 secrets & 1 error, got %v, %v", secrets, errs)
+	}
+	// API_KEY sorts first, so api-key is the conflict.
+	if secrets[0].Name != "API_KEY" {
+		t.Errorf("Got %s", secrets[0].Name)
+	}
+}
@@ -0,0 +1,85 @@
+package project
+
+import (
+	"strings"
+
+	"github.com/starriver/gobbo/pkg/godot"
+)
+
+// Project setting overrides, in [export.<variant>.settings]. Keys are setting
+// paths, eg. "application/config/name" - either quoted, or as nested tables.
+// Values are encoded for project.godot here.
+
+// Returns nil if the table isn't set.
+func parseSettings(root map[string]any, location string, pushErrorf func(string, ...any)) map[string]string {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	table, ok := popTable(location, false)
+	if !ok {
+		return nil
+	}
+
+	settings := map[string]string{}
+	flattenSettings(table, "", location, settings, pushErrorf)
+	for path := range settings {
+		if !strings.Contains(path, "/") {
+			pushErrorf("'%s.%s': expected a setting path like 'section/key'", location, path)
+			delete(settings, path)
+		}
+	}
+	return settings
+}
+
+func flattenSettings(
+	table map[string]any,
+	prefix string,
+	location string,
+	settings map[string]string,
+	pushErrorf func(string, ...any),
+) {
+	for k, v := range table {
+		path := prefix + k
+		if sub, ok := v.(map[string]any); ok {
+			flattenSettings(sub, path+"/", location, settings, pushErrorf)
+		} else if encoded, err := godot.Encode(v); err != nil {
+			pushErrorf("'%s.%s': %v", location, path, err)
+		} else {
+			settings[path] = encoded
+		}
+
+		// Settings paths may contain dots, so these can't be popped by path.
+		delete(table, k)
+	}
+}
+
+// Export preset option overrides, in [export.<variant>.presets.<preset>].
+// Presets are keyed by name or slug; their values are encoded as for settings.
+func parsePresetOptions(root map[string]any, location string, pushErrorf func(string, ...any)) map[string]map[string]string {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	table, ok := popTable(location, false)
+	if !ok {
+		return nil
+	}
+
+	presets := make(map[string]map[string]string, len(table))
+	for preset, v := range table {
+		// Presets may contain dots, so these can't be popped by path.
+		loc := location + "." + preset
+		options, ok := v.(map[string]any)
+		if !ok {
+			pushErrorf("'%s': expected preset options, got %T", loc, v)
+			delete(table, preset)
+			continue
+		}
+
+		presets[preset] = map[string]string{}
+		flattenSettings(options, "", loc, presets[preset], pushErrorf)
+		delete(table, preset)
+	}
+	return presets
+}
+
+// Split a setting path into its project.godot section & key.
+func SplitSetting(path string) (section, key string) {
+	section, key, _ = strings.Cut(path, "/")
+	return
+}
@@ -0,0 +1,75 @@
+package project
+
+import (
+	"slices"
+	"strings"
+)
+
+// Windows code signing config, in [export.sign] or [export.<variant>.sign].
+// The certificate & password are the names of secrets in [export.secrets].
+
+type Signing struct {
+	// PKCS#12 certificate secret.
+	Certificate string
+	// Optional password secret.
+	Password string
+	// RFC 3161 timestamp server URL.
+	Timestamp string
+	// Description & URL embedded in the signature.
+	Description string
+	URL         string
+	// If true, the cell fails unless the PCK is embedded in the executable,
+	// so that the signature covers it.
+	EmbeddedPCK bool
+	Verify      bool
+}
+
+// Returns nil if the table isn't set.
+func parseSigning(
+	root map[string]any,
+	location string,
+	secrets []Secret,
+	pushErrorf func(string, ...any),
+) *Signing {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	if _, ok := popTable(location, false); !ok {
+		return nil
+	}
+
+	popString := popFunc[string](root, pushErrorf)
+	popBool := popFunc[bool](root, pushErrorf)
+	key := func(k string) string { return location + "." + k }
+
+	s := &Signing{Verify: true}
+	s.Certificate, _ = popString(key("certificate"), true)
+	s.Password, _ = popString(key("password"), false)
+	s.Timestamp, _ = popString(key("timestamp"), false)
+	s.Description, _ = popString(key("description"), false)
+	s.URL, _ = popString(key("url"), false)
+	s.EmbeddedPCK, _ = popBool(key("embedded_pck"), false)
+	if verify, ok := popBool(key("verify"), false); ok {
+		s.Verify = verify
+	}
+
+	hasSecret := func(name string) bool {
+		return slices.ContainsFunc(secrets, func(s Secret) bool {
+			return s.Name == name
+		})
+	}
+	for k, name := range map[string]string{
+		"certificate": s.Certificate,
+		"password":    s.Password,
+	} {
+		if name != "" && !hasSecret(name) {
+			pushErrorf("'%s': no secret named '%s' in [export.secrets]", key(k), name)
+		}
+	}
+
+	if s.Timestamp != "" &&
+		!strings.HasPrefix(s.Timestamp, "http://") &&
+		!strings.HasPrefix(s.Timestamp, "https://") {
+		pushErrorf("'%s': expected an HTTP(S) URL, got '%s'", key("timestamp"), s.Timestamp)
+	}
+
+	return s
+}
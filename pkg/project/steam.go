@@ -0,0 +1,65 @@
+package project
+
+// SteamPipe build config, in [export.<variant>.steam]. Only variants can
+// have one, as each Steam build needs its own depots.
+
+type Steam struct {
+	AppID int64
+	// Depot IDs, keyed by preset name or slug. Several presets may share a
+	// depot.
+	Depots map[string]int64
+	// Branch to set the build live on. Empty means the build isn't set live.
+	Branch      string
+	Description string
+	// Steam account to run steamcmd with. Its credentials must already be
+	// cached by steamcmd.
+	Login string
+	// If true, the build is uploaded with steamcmd after exporting.
+	Upload bool
+}
+
+// Returns nil if the table isn't set.
+func parseSteam(root map[string]any, location string, pushErrorf func(string, ...any)) *Steam {
+	popTable := popFunc[map[string]any](root, pushErrorf)
+	if _, ok := popTable(location, false); !ok {
+		return nil
+	}
+
+	popString := popFunc[string](root, pushErrorf)
+	popInt := popFunc[int64](root, pushErrorf)
+	popBool := popFunc[bool](root, pushErrorf)
+	key := func(k string) string { return location + "." + k }
+
+	s := &Steam{Depots: map[string]int64{}}
+	var ok bool
+	if s.AppID, ok = popInt(key("app_id"), true); ok && s.AppID <= 0 {
+		pushErrorf("'%s': expected a positive ID, got %d", key("app_id"), s.AppID)
+	}
+
+	depots, ok := popTable(key("depots"), true)
+	if ok && len(depots) == 0 {
+		pushErrorf("'%s': at least one depot is required", key("depots"))
+	}
+	for preset := range depots {
+		// Presets may contain dots, so these can't be popped by path.
+		id, ok := depots[preset].(int64)
+		if !ok || id <= 0 {
+			pushErrorf("'%s': expected a positive depot ID, got %v", key("depots."+preset), depots[preset])
+		}
+		s.Depots[preset] = id
+		delete(depots, preset)
+	}
+
+	s.Branch, _ = popString(key("branch"), false)
+	if s.Branch == "default" {
+		pushErrorf("'%s': builds can't be set live on the default branch", key("branch"))
+	}
+	s.Description, _ = popString(key("description"), false)
+	s.Login, _ = popString(key("login"), false)
+	s.Upload, _ = popBool(key("upload"), false)
+	if s.Upload && s.Login == "" {
+		pushErrorf("'%s': required to upload", key("login"))
+	}
+
+	return s
+}
@@ -0,0 +1,97 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+// Version derivation, from export.version. Either:
+//
+//	version = "git"
+//	version = { command = "./scripts/version.sh" }
+//
+// The derived version replaces config/version when exporting.
+
+type Version struct {
+	// Use git describe --tags, minus any leading 'v'.
+	Git bool
+	// Or, run a shell command in the project's root directory.
+	Command string
+}
+
+var gitTagVersionRe = regexp.MustCompile(`^v[0-9]`)
+
+// Returns nil if export.version isn't set.
+func parseVersion(root map[string]any, pushErrorf func(string, ...any)) *Version {
+	const location = "export.version"
+
+	popAny := popFunc[any](root, pushErrorf)
+	v, ok := popAny(location, false)
+	if !ok {
+		return nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		if v != "git" {
+			pushErrorf("'%s': expected \"git\" or a table, got '%s'", location, v)
+			return nil
+		}
+		return &Version{Git: true}
+
+	case map[string]any:
+		popString := popFunc[string](root, pushErrorf)
+		command, ok := popString(location+".command", true)
+		if !ok {
+			return nil
+		}
+		return &Version{Command: command}
+	}
+
+	pushErrorf("'%s': expected \"git\" or a table, got %T", location, v)
+	return nil
+}
+
+// Derive the project's version from export.version, replacing Version. Does
+// nothing if it isn't set.
+func (p *Project) ResolveVersion() error {
+	v := p.Export.Version
+	if v == nil {
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if v.Git {
+		cmd = exec.Command("git", "describe", "--tags")
+	} else {
+		cmd = exec.Command("sh", "-c", v.Command)
+	}
+	cmd.Dir = p.Root
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("couldn't derive version: %v", err)
+	}
+
+	version := strings.TrimSpace(string(output))
+	if v.Git && gitTagVersionRe.MatchString(version) {
+		version = version[1:]
+	}
+
+	switch {
+	case version == "":
+		return fmt.Errorf("couldn't derive version: command output was empty")
+	case strings.ContainsAny(version, "\"\\\n"):
+		return fmt.Errorf("derived version '%s' can't contain quotes, backslashes or newlines", version)
+	}
+
+	glog.Infof("Project version: %s", version)
+	p.Version = version
+	return nil
+}
@@ -0,0 +1,40 @@
+package project
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	var errs []error
+	pushErrorf := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	root := map[string]any{"export": map[string]any{
+		"version": map[string]any{"command": "echo ' 1.2.3-beta '"},
+	}}
+	v := parseVersion(root, pushErrorf)
+	if len(errs) != 0 || v == nil {
+		t.Fatalf("Got %v, %v", v, errs)
+	}
+
+	p := &Project{Root: t.TempDir(), Version: "1.0"}
+	p.Export.Version = v
+	if err := p.ResolveVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != "1.2.3-beta" {
+		t.Errorf("Got version '%s'", p.Version)
+	}
+
+	p.Export.Version = &Version{Command: `echo 'a"b'`}
+	if err := p.ResolveVersion(); err == nil {
+		t.Error("Expected an error for a quoted version")
+	}
+
+	root = map[string]any{"export": map[string]any{"version": "hg"}}
+	if parseVersion(root, pushErrorf) != nil || len(errs) != 1 {
+		t.Errorf("Expected an error for 'hg', got %v", errs)
+	}
+}
@@ -0,0 +1,231 @@
+package project
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Container volumes, mirroring Docker Compose's long syntax. These are
+// serialized directly into the exporter's Compose config.
+// See: https://docs.docker.com/reference/compose-file/services/#long-syntax-5
+
+type Volume struct {
+	Type     string
+	Source   string `yaml:",omitempty"`
+	Target   string
+	ReadOnly bool          `yaml:"read_only,omitempty"`
+	Bind     Bind          `yaml:",omitempty"`
+	Volume   VolumeOptions `yaml:",omitempty"`
+	Tmpfs    Tmpfs         `yaml:",omitempty"`
+}
+
+type Bind struct {
+	Propagation    string `yaml:",omitempty"`
+	CreateHostPath bool   `yaml:"create_host_path,omitempty"`
+	SELinux        string `yaml:",omitempty"`
+}
+
+type VolumeOptions struct {
+	NoCopy  bool   `yaml:"nocopy,omitempty"`
+	Subpath string `yaml:",omitempty"`
+}
+
+type Tmpfs struct {
+	Size string `yaml:",omitempty"`
+	Mode uint32 `yaml:",omitempty"`
+}
+
+var volumeTypes = []string{"bind", "volume", "tmpfs"}
+
+var propagations = []string{
+	"shared", "slave", "private", "rshared", "rslave", "rprivate",
+}
+
+// Parse an array of volumes, each of which may be a short-syntax string or a
+// long-syntax table. Relative bind mount sources are resolved against base.
+func parseVolumes(
+	location string,
+	a []any,
+	base string,
+	pushErrorf func(string, ...any),
+) []Volume {
+	volumes := make([]Volume, 0, len(a))
+
+	for i, item := range a {
+		loc := fmt.Sprintf("%s[%d]", location, i)
+		errorf := func(format string, a ...any) {
+			pushErrorf("'%s': "+format, append([]any{loc}, a...)...)
+		}
+
+		var v Volume
+		var ok bool
+		switch item := item.(type) {
+		case string:
+			v, ok = parseShortVolume(item, errorf)
+		case map[string]any:
+			v, ok = parseLongVolume(item, errorf)
+		default:
+			errorf("expected string or table, got %T", item)
+		}
+		if !ok {
+			continue
+		}
+
+		if v.Type == "bind" && !filepath.IsAbs(v.Source) {
+			v.Source = filepath.Join(base, v.Source)
+		}
+		volumes = append(volumes, v)
+	}
+
+	return volumes
+}
+
+// SOURCE:TARGET[:FLAGS]. Sources beginning with '.' or '/' are bind mounts;
+// anything else is a named volume.
+func parseShortVolume(s string, errorf func(string, ...any)) (v Volume, ok bool) {
+	split := strings.Split(s, ":")
+	switch len(split) {
+	case 2, 3: // OK
+	default:
+		errorf("expected 'SOURCE:TARGET[:FLAGS]', got '%s'", s)
+		return
+	}
+
+	v.Source = split[0]
+	v.Target = split[1]
+	switch {
+	case v.Source == "":
+		errorf("empty source")
+		return
+	case v.Source[0] == '.' || v.Source[0] == '/':
+		v.Type = "bind"
+		v.Bind.CreateHostPath = true
+	default:
+		v.Type = "volume"
+	}
+
+	if len(split) == 3 {
+		for _, flag := range strings.Split(split[2], ",") {
+			switch {
+			case flag == "ro":
+				v.ReadOnly = true
+			case flag == "rw":
+				v.ReadOnly = false
+			case v.Type == "bind" && (flag == "z" || flag == "Z"):
+				v.Bind.SELinux = flag
+			case v.Type == "bind" && slices.Contains(propagations, flag):
+				v.Bind.Propagation = flag
+			case v.Type == "volume" && flag == "nocopy":
+				v.Volume.NoCopy = true
+			default:
+				errorf("unsupported %s flag '%s'", v.Type, flag)
+				return
+			}
+		}
+	}
+
+	ok = validateVolume(&v, errorf)
+	return
+}
+
+func parseLongVolume(table map[string]any, errorf func(string, ...any)) (v Volume, ok bool) {
+	errorCount := 0
+	countErrorf := func(format string, a ...any) {
+		errorCount++
+		errorf(format, a...)
+	}
+
+	popString := popFunc[string](table, countErrorf)
+	popBool := popFunc[bool](table, countErrorf)
+	popAny := popFunc[any](table, countErrorf)
+
+	v.Type, _ = popString("type", true)
+	v.Source, _ = popString("source", false)
+	v.Target, _ = popString("target", true)
+	v.ReadOnly, _ = popBool("read_only", false)
+
+	v.Bind.Propagation, _ = popString("bind.propagation", false)
+	v.Bind.CreateHostPath, _ = popBool("bind.create_host_path", false)
+	v.Bind.SELinux, _ = popString("bind.selinux", false)
+
+	v.Volume.NoCopy, _ = popBool("volume.nocopy", false)
+	v.Volume.Subpath, _ = popString("volume.subpath", false)
+
+	if size, ok := popAny("tmpfs.size", false); ok {
+		switch size := size.(type) {
+		case int64:
+			v.Tmpfs.Size = fmt.Sprint(size)
+		case string:
+			v.Tmpfs.Size = size
+		default:
+			countErrorf("'tmpfs.size': expected int or string, got %T", size)
+		}
+	}
+	if mode, ok := popAny("tmpfs.mode", false); ok {
+		m, isInt := mode.(int64)
+		if !isInt || m < 0 || m > 0o7777 {
+			countErrorf("'tmpfs.mode': expected file mode, got %v", mode)
+		}
+		v.Tmpfs.Mode = uint32(m)
+	}
+
+	for _, k := range scanKeys(table, "") {
+		countErrorf("'%s': unknown key", k)
+	}
+
+	if errorCount != 0 {
+		return
+	}
+
+	ok = validateVolume(&v, errorf)
+	return
+}
+
+func validateVolume(v *Volume, errorf func(string, ...any)) bool {
+	ok := true
+	fail := func(format string, a ...any) {
+		errorf(format, a...)
+		ok = false
+	}
+
+	if !slices.Contains(volumeTypes, v.Type) {
+		fail("unsupported type '%s', expected one of %v", v.Type, volumeTypes)
+		return false
+	}
+
+	if !strings.HasPrefix(v.Target, "/") {
+		fail("target must be an absolute path, got '%s'", v.Target)
+	}
+
+	if v.Type == "tmpfs" {
+		if v.Source != "" {
+			fail("tmpfs volumes can't have a source")
+		}
+	} else if v.Source == "" {
+		fail("%s volumes require a source", v.Type)
+	}
+
+	if v.Type != "bind" && v.Bind != (Bind{}) {
+		fail("'bind' options are only valid for bind mounts")
+	}
+	if v.Type != "volume" && v.Volume != (VolumeOptions{}) {
+		fail("'volume' options are only valid for named volumes")
+	}
+	if v.Type != "tmpfs" && v.Tmpfs != (Tmpfs{}) {
+		fail("'tmpfs' options are only valid for tmpfs mounts")
+	}
+
+	p := v.Bind.Propagation
+	if p != "" && !slices.Contains(propagations, p) {
+		fail("unsupported bind propagation '%s'", p)
+	}
+	switch v.Bind.SELinux {
+	case "", "z", "Z": // OK
+	default:
+		fail("unsupported SELinux relabelling '%s'", v.Bind.SELinux)
+	}
+
+	return ok
+}
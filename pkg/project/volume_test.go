@@ -0,0 +1,94 @@
+package project
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVolumes(t *testing.T) {
+	var errs []error
+	pushErrorf := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	good := []any{
+		"./steam:/opt/steam:ro,z",
+		"cache:/root/.cache:nocopy",
+		map[string]any{
+			"type":   "bind",
+			"source": "/srv/assets",
+			"target": "/srv/assets",
+			"bind": map[string]any{
+				"propagation":      "rslave",
+				"create_host_path": true,
+			},
+		},
+		map[string]any{
+			"type":   "tmpfs",
+			"target": "/tmp",
+			"tmpfs":  map[string]any{"size": int64(1 << 20), "mode": int64(0o1777)},
+		},
+	}
+
+	volumes := parseVolumes("export.volumes", good, "project", pushErrorf)
+	if len(errs) != 0 {
+		t.Fatalf("Got errors: %v", errs)
+	}
+
+	expected := []Volume{
+		{
+			Type:     "bind",
+			Source:   "project/steam",
+			Target:   "/opt/steam",
+			ReadOnly: true,
+			Bind:     Bind{CreateHostPath: true, SELinux: "z"},
+		},
+		{
+			Type:   "volume",
+			Source: "cache",
+			Target: "/root/.cache",
+			Volume: VolumeOptions{NoCopy: true},
+		},
+		{
+			Type:   "bind",
+			Source: "/srv/assets",
+			Target: "/srv/assets",
+			Bind:   Bind{Propagation: "rslave", CreateHostPath: true},
+		},
+		{
+			Type:   "tmpfs",
+			Target: "/tmp",
+			Tmpfs:  Tmpfs{Size: "1048576", Mode: 0o1777},
+		},
+	}
+	for i, v := range volumes {
+		if v != expected[i] {
+			t.Errorf("Got %+v, expected %+v", v, expected[i])
+		}
+	}
+
+	bad := []any{
+		"/no/target",
+		"./relative:target",
+		"./src:/dst:nocopy",
+		int64(1),
+		map[string]any{"type": "npipe", "source": "x", "target": "/x"},
+		map[string]any{"type": "tmpfs", "source": "x", "target": "/x"},
+		map[string]any{"type": "volume", "target": "/x"},
+		map[string]any{"type": "bind", "source": ".", "target": "/x", "oops": true},
+		map[string]any{
+			"type":   "volume",
+			"source": "x",
+			"target": "/x",
+			"bind":   map[string]any{"propagation": "shared"},
+		},
+	}
+
+	for i, item := range bad {
+		errs = nil
+		volumes = parseVolumes("export.volumes", []any{item}, ".", pushErrorf)
+		if len(errs) == 0 || len(volumes) != 0 {
+			t.Errorf("%d: expected error for %v", i, item)
+		}
+	}
+}
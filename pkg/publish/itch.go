@@ -0,0 +1,174 @@
+package publish
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/export"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// Publishing to itch.io with butler.
+// See: https://itch.io/docs/butler/pushing.html
+
+// The butler executable. Overridden in tests.
+var Butler = "butler"
+
+// Default channels by platform. butler tags channels containing these names
+// with the right platform automatically.
+var itchChannels = map[string]string{
+	"Android":         "android",
+	"iOS":             "ios",
+	"Linux":           "linux",
+	"macOS":           "mac",
+	"Web":             "html5",
+	"Windows Desktop": "windows",
+}
+
+var channelRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+type ItchPush struct {
+	Cell    string
+	Target  string
+	Channel string
+	Version string
+	// File or directory to push. Set by Pushes().
+	Source string
+}
+
+// The itch config for a cell's variant, if any.
+func itchConfig(p *project.Project, variant string) *project.ItchPublish {
+	publish := p.Export.Publish
+	if v, ok := p.Export.Variants[variant]; ok && v.Publish != nil {
+		publish = v.Publish
+	}
+	if publish == nil {
+		return nil
+	}
+	return publish.Itch
+}
+
+// Work out the itch pushes for each service, and validate their channels.
+// This should be called before exporting, so that misconfiguration fails fast.
+func ItchPlan(c *export.ComposeConfig, p *project.Project) ([]ItchPush, error) {
+	pushes := []ItchPush{}
+	errs := []string{}
+	seen := map[string]string{}
+
+	// Sorted, so conflicting channels are reported consistently.
+	for _, name := range slices.Sorted(maps.Keys(c.Services)) {
+		env := c.Services[name].Environment
+		itch := itchConfig(p, env.ExportVariant)
+		if itch == nil {
+			continue
+		}
+
+		channel, ok := itch.Channels[env.ExportPreset]
+		if !ok {
+			channel, ok = itch.Channels[slug.Make(env.ExportPreset)]
+		}
+		if !ok {
+			channel, ok = itchChannels[env.ExportPlatform]
+		}
+		if !ok {
+			errs = append(errs, fmt.Sprintf(
+				"%s: no default channel for platform '%s' - set one in 'channels'",
+				name, env.ExportPlatform,
+			))
+			continue
+		}
+
+		if !channelRe.MatchString(channel) {
+			errs = append(errs, fmt.Sprintf(
+				"%s: invalid channel name '%s' (use lowercase letters, digits, '.', '_' & '-')",
+				name, channel,
+			))
+			continue
+		}
+
+		target := itch.Target + ":" + channel
+		if other, ok := seen[target]; ok {
+			errs = append(errs, fmt.Sprintf(
+				"%s: channel '%s' is also used by %s - set one in 'channels'",
+				name, target, other,
+			))
+			continue
+		}
+		seen[target] = name
+
+		pushes = append(pushes, ItchPush{
+			Cell:    name,
+			Target:  itch.Target,
+			Channel: channel,
+			Version: env.ProjectVersion,
+		})
+	}
+
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("invalid itch.io config:\n%s", strings.Join(errs, "\n"))
+	}
+	return pushes, nil
+}
+
+// Fill in the source of each planned push from the manifest. Pushes for cells
+// that didn't produce anything are dropped.
+func Pushes(plan []ItchPush, m *export.Manifest, dist string) []ItchPush {
+	pushes := []ItchPush{}
+	for _, push := range plan {
+		var artifacts []string
+		for _, a := range m.Artifacts {
+			if a.Cell == push.Cell {
+				artifacts = append(artifacts, a.Path)
+			}
+		}
+
+		switch {
+		case len(artifacts) == 0:
+			glog.Warnf("%s: nothing to publish", push.Cell)
+			continue
+		case len(artifacts) == 1 && path.Ext(artifacts[0]) == ".zip":
+			// Zipped exports are pushed as-is.
+			push.Source = filepath.Join(dist, filepath.FromSlash(artifacts[0]))
+		default:
+			push.Source = filepath.Join(dist, filepath.FromSlash(path.Dir(artifacts[0])))
+		}
+
+		pushes = append(pushes, push)
+	}
+	return pushes
+}
+
+func (push *ItchPush) args() []string {
+	args := []string{"push", push.Source, push.Target + ":" + push.Channel}
+	if push.Version != "" {
+		args = append(args, "--userversion", push.Version)
+	}
+	return args
+}
+
+// Push with butler. If dryRun is true, the command is only logged.
+func (push *ItchPush) Run(dryRun bool) error {
+	args := push.args()
+	if dryRun {
+		glog.Infof("Would run: %s %s", Butler, strings.Join(args, " "))
+		return nil
+	}
+
+	glog.Infof("Publishing %s to %s:%s", push.Cell, push.Target, push.Channel)
+	cmd := exec.Command(Butler, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("butler push failed for %s: %v", push.Cell, err)
+	}
+	return nil
+}
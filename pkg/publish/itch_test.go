@@ -0,0 +1,135 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/starriver/gobbo/pkg/export"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+func itchProject(channels map[string]string) *project.Project {
+	p := &project.Project{}
+	p.Export.Variants = map[string]*project.Variant{
+		"itch": {
+			Publish: &project.Publish{Itch: &project.ItchPublish{
+				Target:   "studio/game",
+				Channels: channels,
+			}},
+		},
+		"steam": {},
+	}
+	return p
+}
+
+func composeConfig(services map[string]export.Environment) *export.ComposeConfig {
+	c := &export.ComposeConfig{Services: map[string]export.Service{}}
+	for name, env := range services {
+		c.Services[name] = export.Service{Environment: env}
+	}
+	return c
+}
+
+func TestItchPlan(t *testing.T) {
+	c := composeConfig(map[string]export.Environment{
+		"itch_linux": {
+			ExportPreset: "Linux", ExportPlatform: "Linux",
+			ExportVariant: "itch", ProjectVersion: "1.2",
+		},
+		"itch_web": {
+			ExportPreset: "Web", ExportPlatform: "Web", ExportVariant: "itch",
+		},
+		"itch_linux-demo": {
+			ExportPreset: "Linux Demo", ExportPlatform: "Linux", ExportVariant: "itch",
+		},
+		"steam_linux": {
+			ExportPreset: "Linux", ExportPlatform: "Linux", ExportVariant: "steam",
+		},
+	})
+
+	// Both Linux presets default to the same channel.
+	_, err := ItchPlan(c, itchProject(map[string]string{}))
+	if err == nil || !strings.Contains(err.Error(), "itch_linux-demo: channel") ||
+		!strings.HasSuffix(err.Error(), "also used by itch_linux - set one in 'channels'") {
+		t.Errorf("Expected channel collision, got %v", err)
+	}
+
+	_, err = ItchPlan(c, itchProject(map[string]string{"linux-demo": "Linux Demo"}))
+	if err == nil || !strings.Contains(err.Error(), "invalid channel name") {
+		t.Errorf("Expected invalid channel, got %v", err)
+	}
+
+	plan, err := ItchPlan(c, itchProject(map[string]string{"Linux Demo": "linux-demo"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ItchPush{
+		{Cell: "itch_linux", Target: "studio/game", Channel: "linux", Version: "1.2"},
+		{Cell: "itch_linux-demo", Target: "studio/game", Channel: "linux-demo"},
+		{Cell: "itch_web", Target: "studio/game", Channel: "html5"},
+	}
+	if len(plan) != len(expected) {
+		t.Fatalf("Got %+v", plan)
+	}
+	for i := range expected {
+		if plan[i] != expected[i] {
+			t.Errorf("Got %+v, expected %+v", plan[i], expected[i])
+		}
+	}
+}
+
+func TestItchPush(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake butler is a shell script")
+	}
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "butler.log")
+
+	// Stand-in for butler that records its arguments.
+	Butler = filepath.Join(dir, "butler")
+	defer func() { Butler = "butler" }()
+	script := "#!/bin/sh\necho \"$@\" >> '" + log + "'\n"
+	if err := os.WriteFile(Butler, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := []ItchPush{
+		{Cell: "itch_linux", Target: "studio/game", Channel: "linux", Version: "1.2"},
+		{Cell: "itch_web", Target: "studio/game", Channel: "html5"},
+		{Cell: "itch_windows", Target: "studio/game", Channel: "windows"},
+	}
+	m := &export.Manifest{Artifacts: []export.Artifact{
+		{Cell: "itch_linux", Path: "itch/linux/game.x86_64"},
+		{Cell: "itch_linux", Path: "itch/linux/game.pck"},
+		{Cell: "itch_web", Path: "itch/web.zip"},
+	}}
+
+	// itch_windows has no artifacts, so is dropped.
+	pushes := Pushes(plan, m, "/dist")
+	if len(pushes) != 2 {
+		t.Fatalf("Got %+v", pushes)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		for i := range pushes {
+			if err := pushes[i].Run(dryRun); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "push /dist/itch/linux studio/game:linux --userversion 1.2\n" +
+		"push /dist/itch/web.zip studio/game:html5\n"
+	if string(b) != expected {
+		t.Errorf("Got butler calls:\n%s", b)
+	}
+}
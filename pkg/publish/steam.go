@@ -0,0 +1,272 @@
+package publish
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/starriver/gobbo/pkg/export"
+	"github.com/starriver/gobbo/pkg/glog"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+// SteamPipe builds, generated from [export.<variant>.steam].
+// See: https://partner.steamgames.com/doc/sdk/uploading
+
+// The steamcmd executable. Overridden in tests.
+var SteamCmd = "steamcmd"
+
+// Directory in dist that Steam builds are laid out in.
+const SteamDir = ".steam"
+
+type SteamBuild struct {
+	Variant string
+	Config  *project.Steam
+}
+
+// The depot for a preset, by name or slug.
+func steamDepot(s *project.Steam, preset string) (int64, bool) {
+	if id, ok := s.Depots[preset]; ok {
+		return id, true
+	}
+	id, ok := s.Depots[slug.Make(preset)]
+	return id, ok
+}
+
+// Work out the Steam builds for the variants being exported, and check each
+// of their cells has a depot. This should be called before exporting.
+func SteamPlan(c *export.ComposeConfig, p *project.Project) ([]SteamBuild, error) {
+	builds := []SteamBuild{}
+	errs := []string{}
+
+	for name, s := range c.Services {
+		env := &s.Environment
+		v, ok := p.Export.Variants[env.ExportVariant]
+		if !ok || v.Steam == nil {
+			continue
+		}
+
+		if _, ok := steamDepot(v.Steam, env.ExportPreset); !ok {
+			errs = append(errs, fmt.Sprintf(
+				"%s: no Steam depot for preset '%s' - add one to 'depots', or exclude it with 'only'",
+				name, env.ExportPreset,
+			))
+		}
+
+		if !slices.ContainsFunc(builds, func(b SteamBuild) bool {
+			return b.Variant == env.ExportVariant
+		}) {
+			builds = append(builds, SteamBuild{env.ExportVariant, v.Steam})
+		}
+	}
+
+	if len(builds) != 0 && p.Export.Zip {
+		errs = append(errs, "Steam builds need unzipped exports - unset 'export.zip'")
+	}
+
+	if len(errs) != 0 {
+		slices.Sort(errs)
+		return nil, fmt.Errorf("invalid Steam config:\n%s", strings.Join(errs, "\n"))
+	}
+
+	slices.SortFunc(builds, func(a, b SteamBuild) int {
+		return strings.Compare(a.Variant, b.Variant)
+	})
+	return builds, nil
+}
+
+// Directory the build's scripts, content & output are written to.
+func (b *SteamBuild) Root(dist string) string {
+	return filepath.Join(dist, SteamDir, slug.Make(b.Variant))
+}
+
+// Lay out the content of each depot from the variant's artifacts in the
+// manifest, and write the SteamPipe build scripts. Returns the path of the app
+// build script.
+func (b *SteamBuild) Generate(m *export.Manifest, dist string) (string, error) {
+	dist, err := filepath.Abs(dist)
+	if err != nil {
+		return "", err
+	}
+
+	root := b.Root(dist)
+	content := filepath.Join(root, "content")
+	scripts := filepath.Join(root, "scripts")
+
+	// Start afresh, so that stale files aren't uploaded.
+	if err = os.RemoveAll(content); err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(scripts, 0o755); err != nil {
+		return "", err
+	}
+
+	depots := []int64{}
+	version := ""
+	for _, a := range m.Artifacts {
+		if a.Variant != b.Variant {
+			continue
+		}
+		depot, ok := steamDepot(b.Config, a.Preset)
+		if !ok {
+			glog.Warnf("%s: no Steam depot for '%s', skipping", b.Variant, a.Path)
+			continue
+		}
+		if !slices.Contains(depots, depot) {
+			depots = append(depots, depot)
+		}
+		version = a.ProjectVersion
+
+		src := filepath.Join(dist, filepath.FromSlash(a.Path))
+		rel, err := filepath.Rel(export.CellDir(dist, a.Variant, a.Preset), src)
+		if err != nil {
+			return "", err
+		}
+		dest := filepath.Join(content, strconv.FormatInt(depot, 10), rel)
+		if err = linkOrCopy(src, dest); err != nil {
+			return "", fmt.Errorf("couldn't lay out '%s': %v", a.Path, err)
+		}
+	}
+
+	if len(depots) == 0 {
+		return "", fmt.Errorf("%s: nothing to build for Steam", b.Variant)
+	}
+	slices.Sort(depots)
+
+	desc := b.Config.Description
+	if desc == "" && version != "" {
+		desc = "v" + version
+	}
+
+	depotScripts := []vdf{}
+	for _, depot := range depots {
+		id := strconv.FormatInt(depot, 10)
+		name := "depot_build_" + id + ".vdf"
+		depotScripts = append(depotScripts, vdf{id, name})
+
+		err = writeVDF(filepath.Join(scripts, name), []vdf{{"DepotBuild", []vdf{
+			{"DepotID", id},
+			{"ContentRoot", filepath.Join(content, id)},
+			{"FileMapping", []vdf{
+				{"LocalPath", "*"},
+				{"DepotPath", "."},
+				{"Recursive", "1"},
+			}},
+		}}})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	app := []vdf{
+		{"AppID", strconv.FormatInt(b.Config.AppID, 10)},
+		{"Desc", desc},
+		{"ContentRoot", content},
+		{"BuildOutput", filepath.Join(root, "output")},
+	}
+	if b.Config.Branch != "" {
+		app = append(app, vdf{"SetLive", b.Config.Branch})
+	}
+	app = append(app, vdf{"Depots", depotScripts})
+
+	appScript := filepath.Join(
+		scripts,
+		fmt.Sprintf("app_build_%d.vdf", b.Config.AppID),
+	)
+	err = writeVDF(appScript, []vdf{{"AppBuild", app}})
+	if err != nil {
+		return "", err
+	}
+
+	glog.Infof("Wrote Steam build for %s to '%s'", b.Variant, root)
+	return appScript, nil
+}
+
+// Upload a generated build with steamcmd. If dryRun is true, the command is
+// only logged.
+func (b *SteamBuild) Upload(appScript string, dryRun bool) error {
+	args := []string{
+		"+login", b.Config.Login,
+		"+run_app_build", appScript,
+		"+quit",
+	}
+	if dryRun {
+		glog.Infof("Would run: %s %s", SteamCmd, strings.Join(args, " "))
+		return nil
+	}
+
+	glog.Infof("Uploading %s to Steam app %d", b.Variant, b.Config.AppID)
+	cmd := exec.Command(SteamCmd, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("steamcmd failed for %s: %v", b.Variant, err)
+	}
+	return nil
+}
+
+// A VDF key-value pair. The value is either a string or []vdf.
+type vdf struct {
+	key   string
+	value any
+}
+
+func writeVDF(path string, pairs []vdf) error {
+	var sb strings.Builder
+	formatVDF(&sb, pairs, 0)
+	return os.WriteFile(path, []byte(sb.String()), 0o644)
+}
+
+func formatVDF(sb *strings.Builder, pairs []vdf, depth int) {
+	indent := strings.Repeat("\t", depth)
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	for _, kv := range pairs {
+		switch v := kv.value.(type) {
+		case string:
+			fmt.Fprintf(sb, "%s\"%s\"\t\"%s\"\n", indent, quote.Replace(kv.key), quote.Replace(v))
+		case []vdf:
+			fmt.Fprintf(sb, "%s\"%s\"\n%s{\n", indent, quote.Replace(kv.key), indent)
+			formatVDF(sb, v, depth+1)
+			fmt.Fprintf(sb, "%s}\n", indent)
+		}
+	}
+}
+
+// Hard link src to dest, falling back to copying (eg. across filesystems).
+func linkOrCopy(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, st.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
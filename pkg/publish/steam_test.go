@@ -0,0 +1,133 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/starriver/gobbo/pkg/export"
+	"github.com/starriver/gobbo/pkg/project"
+)
+
+func TestSteamPlan(t *testing.T) {
+	p := &project.Project{}
+	p.Export.Variants = map[string]*project.Variant{
+		"steam": {Steam: &project.Steam{
+			AppID:  1000,
+			Depots: map[string]int64{"linux": 1001},
+		}},
+	}
+
+	c := composeConfig(map[string]export.Environment{
+		"steam_linux": {ExportPreset: "Linux", ExportVariant: "steam"},
+		"steam_web":   {ExportPreset: "Web", ExportVariant: "steam"},
+	})
+	_, err := SteamPlan(c, p)
+	if err == nil || !strings.Contains(err.Error(), "no Steam depot for preset 'Web'") {
+		t.Errorf("Expected missing depot, got %v", err)
+	}
+
+	delete(c.Services, "steam_web")
+	builds, err := SteamPlan(c, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 1 || builds[0].Variant != "steam" {
+		t.Errorf("Got %+v", builds)
+	}
+}
+
+func TestSteamGenerate(t *testing.T) {
+	dist := t.TempDir()
+
+	files := []string{
+		"steam/linux/game.x86_64",
+		"steam/linux/game.pck",
+		"steam/windows/game.exe",
+		"steam/windows/data/lib.dll",
+		"itch/linux/game.x86_64",
+	}
+	m := &export.Manifest{}
+	for _, f := range files {
+		path := filepath.Join(dist, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		variant, preset, _ := strings.Cut(f, "/")
+		preset, _, _ = strings.Cut(preset, "/")
+		m.Artifacts = append(m.Artifacts, export.Artifact{
+			Path:           f,
+			Preset:         strings.ToUpper(preset[:1]) + preset[1:],
+			Variant:        variant,
+			ProjectVersion: "1.2",
+		})
+	}
+
+	b := SteamBuild{"steam", &project.Steam{
+		AppID:  1000,
+		Depots: map[string]int64{"Linux": 1001, "windows": 1002},
+		Branch: "beta",
+	}}
+	script, err := b.Generate(m, dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := b.Root(dist)
+	content := filepath.Join(root, "content")
+	for _, f := range []string{
+		"1001/game.x86_64",
+		"1001/game.pck",
+		"1002/game.exe",
+		"1002/data/lib.dll",
+	} {
+		if _, err = os.Stat(filepath.Join(content, f)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	app, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"AppBuild"
+{
+	"AppID"	"1000"
+	"Desc"	"v1.2"
+	"ContentRoot"	"` + content + `"
+	"BuildOutput"	"` + filepath.Join(root, "output") + `"
+	"SetLive"	"beta"
+	"Depots"
+	{
+		"1001"	"depot_build_1001.vdf"
+		"1002"	"depot_build_1002.vdf"
+	}
+}
+`
+	if string(app) != expected {
+		t.Errorf("Got app build:\n%s", app)
+	}
+
+	depot, err := os.ReadFile(filepath.Join(root, "scripts", "depot_build_1002.vdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `"DepotBuild"
+{
+	"DepotID"	"1002"
+	"ContentRoot"	"` + filepath.Join(content, "1002") + `"
+	"FileMapping"
+	{
+		"LocalPath"	"*"
+		"DepotPath"	"."
+		"Recursive"	"1"
+	}
+}
+`
+	if string(depot) != expected {
+		t.Errorf("Got depot build:\n%s", depot)
+	}
+}
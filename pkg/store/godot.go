@@ -121,6 +121,15 @@ func (s *Store) InstallGodot(g *godot.Official) error {
 // This a relatively fuzzy way of normalizing the contents of downloaded release
 // zips.
 func normalize(s *Store, g *godot.Official, tmp string) error {
+	if g.Mono {
+		// .NET zips (other than macOS's .app) have everything in a single
+		// directory, with the GodotSharp directory next to the binary. Move
+		// it all up a level.
+		if err := flatten(tmp); err != nil {
+			return err
+		}
+	}
+
 	dir, err := os.ReadDir(tmp)
 	if err != nil {
 		return err
@@ -209,3 +218,28 @@ func normalize(s *Store, g *godot.Official, tmp string) error {
 
 	return nil
 }
+
+// If dir only contains a single directory (that isn't a macOS .app), move its
+// contents into dir.
+func flatten(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() || strings.HasSuffix(entries[0].Name(), ".app") {
+		return nil
+	}
+
+	inner := filepath.Join(dir, entries[0].Name())
+	children, err := os.ReadDir(inner)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		err = os.Rename(filepath.Join(inner, c.Name()), filepath.Join(dir, c.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return os.Remove(inner)
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+// Poll root for changes, calling onChange whenever any file is added, removed
+// or modified. Paths in exclude (and anything beneath them) are ignored.
+// Never returns.
+func Watch(root string, exclude []string, interval time.Duration, onChange func()) {
+	last := snapshot(root, exclude)
+	for {
+		time.Sleep(interval)
+
+		current := snapshot(root, exclude)
+		if current != last {
+			glog.Debugf("Changes detected in '%s'", root)
+			onChange()
+			// Take a fresh snapshot, in case onChange modified anything.
+			current = snapshot(root, exclude)
+		}
+		last = current
+	}
+}
+
+type tree struct {
+	files  int
+	size   int64
+	latest time.Time
+}
+
+// Cheap summary of a directory tree. Good enough to spot edits, without
+// hashing everything.
+func snapshot(root string, exclude []string) (t tree) {
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Files may be deleted mid-walk - just skip them.
+			return nil
+		}
+		if slices.Contains(exclude, path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		st, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		t.files++
+		t.size += st.Size()
+		if st.ModTime().After(t.latest) {
+			t.latest = st.ModTime()
+		}
+		return nil
+	})
+	return
+}
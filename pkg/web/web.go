@@ -0,0 +1,191 @@
+package web
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/starriver/gobbo/pkg/glog"
+)
+
+// Local preview server for Godot web exports.
+
+// Godot 4 needs SharedArrayBuffer, which browsers only allow in cross-origin
+// isolated contexts.
+var isolationHeaders = map[string]string{
+	"Cross-Origin-Opener-Policy":   "same-origin",
+	"Cross-Origin-Embedder-Policy": "require-corp",
+}
+
+// Large files worth precompressing.
+var compressExts = []string{".wasm", ".pck"}
+
+type encoding struct {
+	name string
+	ext  string
+	new  func(io.Writer) io.WriteCloser
+}
+
+// In order of preference.
+var encodings = []encoding{
+	{"br", ".br", func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	}},
+	{"gzip", ".gz", func(w io.Writer) io.WriteCloser {
+		gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return gz
+	}},
+}
+
+func init() {
+	// Not all systems' MIME databases know about these.
+	mime.AddExtensionType(".wasm", "application/wasm")
+	mime.AddExtensionType(".pck", "application/octet-stream")
+}
+
+// Write brotli & gzip versions of each large file in dir, unless they're
+// already up-to-date.
+func Precompress(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isCompressible(name) {
+			continue
+		}
+
+		src := filepath.Join(dir, name)
+		st, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		for _, enc := range encodings {
+			dest := src + enc.ext
+			if dst, err := os.Stat(dest); err == nil && !dst.ModTime().Before(st.ModTime()) {
+				glog.Debugf("Up-to-date: '%s'", dest)
+				continue
+			}
+
+			glog.Infof("Compressing '%s' (%s)", name, enc.name)
+			if err = compress(src, dest, enc); err != nil {
+				return fmt.Errorf("couldn't compress '%s': %v", src, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isCompressible(name string) bool {
+	return slices.Contains(compressExts, filepath.Ext(name))
+}
+
+func compress(src, dest string, enc encoding) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Write to a tempfile first, so a partial file is never served.
+	out, err := os.CreateTemp(filepath.Dir(dest), ".gobbo-compress-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	w := enc.new(out)
+	if _, err = io.Copy(w, in); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), dest)
+}
+
+// Serve dir with cross-origin isolation headers, preferring precompressed
+// files where the client accepts them. Requests for / are redirected to index.
+func Handler(dir, index string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		glog.Debugf("%s %s", r.Method, r.URL.Path)
+
+		h := w.Header()
+		for k, v := range isolationHeaders {
+			h.Set(k, v)
+		}
+		// Always fetch fresh exports.
+		h.Set("Cache-Control", "no-cache")
+
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/"+index, http.StatusFound)
+			return
+		}
+
+		name := path.Clean(r.URL.Path)
+		if isCompressible(name) {
+			accept := r.Header.Get("Accept-Encoding")
+			for _, enc := range encodings {
+				if !strings.Contains(accept, enc.name) {
+					continue
+				}
+
+				local := filepath.Join(dir, filepath.FromSlash(name)+enc.ext)
+				if _, err := os.Stat(local); err != nil {
+					continue
+				}
+
+				h.Set("Content-Encoding", enc.name)
+				h.Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+				h.Add("Vary", "Accept-Encoding")
+				http.ServeFile(w, r, local)
+				return
+			}
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// Check that a web export's PWA files are present alongside its HTML file.
+func CheckPWA(html string) error {
+	base := strings.TrimSuffix(html, filepath.Ext(html))
+
+	missing := []string{}
+	for _, suffix := range []string{".manifest.json", ".service.worker.js"} {
+		p := base + suffix
+		if _, err := os.Stat(p); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			missing = append(missing, filepath.Base(p))
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf(
+			"PWA files missing: %s (enable progressive_web_app/enabled in the preset)",
+			strings.Join(missing, ", "),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "game.html"), []byte("<html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "game.wasm"), []byte("wasm wasm wasm"), 0o644)
+
+	if err := Precompress(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".br", ".gz"} {
+		if _, err := os.Stat(filepath.Join(dir, "game.wasm"+ext)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	h := Handler(dir, "game.html")
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/", "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/game.html" {
+		t.Errorf("Got %d to '%s', expected redirect", w.Code, w.Header().Get("Location"))
+	}
+	if w.Header().Get("Cross-Origin-Embedder-Policy") != "require-corp" {
+		t.Error("Missing COEP header")
+	}
+
+	cases := []struct{ accept, encoding string }{
+		{"gzip, deflate, br", "br"},
+		{"gzip", "gzip"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		w = get("/game.wasm", c.accept)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got %d", c.accept, w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("%s: got encoding '%s', expected '%s'", c.accept, got, c.encoding)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/wasm" {
+			t.Errorf("%s: got type '%s'", c.accept, got)
+		}
+	}
+}